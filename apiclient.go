@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiError is returned when a forge REST API answers with an error status
+type apiError struct {
+	Method     string
+	URL        string
+	Status     int
+	Message    string
+	Class      errorClass
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: HTTP %d: %s", e.Method, e.URL, e.Status, e.Message)
+}
+
+func (e *apiError) errorClass() errorClass {
+	return e.Class
+}
+
+func (e *apiError) retryAfter() time.Duration {
+	return e.RetryAfter
+}
+
+// apiClient is a small JSON-over-HTTP client shared by the REST backends.
+// It classifies errors and waits for rate limit windows to reset.
+type apiClient struct {
+	baseURL   string
+	http      *http.Client
+	authorize func(req *http.Request)
+	authErr   error // Set when no credentials were found; reported on the first request, so dry runs work without them
+
+	mu           sync.Mutex
+	blockedUntil time.Time // Set when the forge reported that no requests are left
+}
+
+func newAPIClient(baseURL string, authorize func(req *http.Request)) *apiClient {
+	return &apiClient{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		http:      &http.Client{},
+		authorize: authorize,
+	}
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into out.
+// path is relative to the base URL unless it is an absolute URL.
+func (c *apiClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	_, err := c.doResponse(ctx, method, path, body, out)
+	return err
+}
+
+// doResponse is like do but also returns the response headers, for pagination
+func (c *apiClient) doResponse(ctx context.Context, method, path string, body, out interface{}) (http.Header, error) {
+	if c.authErr != nil {
+		return nil, c.authErr
+	}
+	c.waitForRateLimit()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := c.baseURL + path
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		url = path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authorize != nil {
+		c.authorize(req)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, withClass(classTimeout, err)
+		}
+		return nil, withClass(classNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	c.trackRateLimit(resp)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, withClass(classNetwork, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(method, url, resp, data)
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("failed to decode response from %s %s: %w", method, url, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// waitForRateLimit sleeps until the rate limit window resets if the forge reported it exhausted
+func (c *apiClient) waitForRateLimit() {
+	c.mu.Lock()
+	wait := time.Until(c.blockedUntil)
+	c.mu.Unlock()
+
+	if wait > 0 {
+		sleep(wait)
+	}
+}
+
+// trackRateLimit reads the rate limit headers used by GitHub, GitLab and Gitea
+func (c *apiClient) trackRateLimit(resp *http.Response) {
+	remaining := rateLimitHeader(resp.Header, "Remaining")
+	if remaining == "" || remaining != "0" {
+		return
+	}
+	reset, err := strconv.ParseInt(rateLimitHeader(resp.Header, "Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until := time.Unix(reset, 0); until.After(c.blockedUntil) {
+		c.blockedUntil = until
+	}
+}
+
+func rateLimitHeader(header http.Header, name string) string {
+	if v := header.Get("X-RateLimit-" + name); v != "" {
+		return v
+	}
+	return header.Get("RateLimit-" + name)
+}
+
+// newAPIError builds a classified apiError from an error response
+func newAPIError(method, url string, resp *http.Response, data []byte) *apiError {
+	e := &apiError{Method: method, URL: url, Status: resp.StatusCode, Message: apiErrorMessage(data)}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	exhausted := rateLimitHeader(resp.Header, "Remaining") == "0"
+	switch class := classifyOutput(e.Message); {
+	case resp.StatusCode == http.StatusTooManyRequests, exhausted, class == classRateLimited:
+		e.Class = classRateLimited
+	case class == classAlreadyExists:
+		e.Class = classAlreadyExists
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		e.Class = classAuth
+	case resp.StatusCode == http.StatusNotFound:
+		e.Class = classNotFound
+	case resp.StatusCode == http.StatusConflict, resp.StatusCode == http.StatusUnprocessableEntity, resp.StatusCode == http.StatusBadRequest,
+		resp.StatusCode == http.StatusMethodNotAllowed:
+		e.Class = classValidation
+	case resp.StatusCode >= 500:
+		e.Class = classNetwork
+	default:
+		e.Class = class
+	}
+	return e
+}
+
+// apiErrorMessage extracts a readable message from the error bodies of the supported forges
+func apiErrorMessage(data []byte) string {
+	var body struct {
+		Message interface{} `json:"message"`
+		Error   interface{} `json:"error"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return strings.TrimSpace(string(data))
+	}
+
+	var parts []string
+	for _, v := range []interface{}{body.Message, body.Error} {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				parts = append(parts, v)
+			}
+		case nil:
+		default:
+			encoded, _ := json.Marshal(v)
+			parts = append(parts, string(encoded))
+		}
+	}
+	for _, e := range body.Errors {
+		if e.Message != "" {
+			parts = append(parts, e.Message)
+		}
+	}
+	if len(parts) == 0 {
+		return strings.TrimSpace(string(data))
+	}
+	return strings.Join(parts, ": ")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Outcomes of enabling auto-merge, as recorded in the run report
+const (
+	autoMergeEnabled = "enabled"
+	autoMergeFailed  = "failed"
+)
+
+// autoMerger is implemented by backends that can enable auto-merge on pull requests
+type autoMerger interface {
+	// enableAutoMerge makes the forge merge pr with method once its requirements are met
+	enableAutoMerge(ctx context.Context, details Repo, pr *pullRequest, method string) error
+}
+
+// applyAutoMerge enables auto-merge on the pull request of res when details asks for it.
+// A failure is recorded in res and logged, but does not fail the entry: the PR itself exists.
+func applyAutoMerge(ctx context.Context, config *Config, retry retrier, details Repo, res *result) {
+	if details.AutoMerge == "" {
+		return
+	}
+
+	err := func() error {
+		if res.Number == 0 {
+			return fmt.Errorf("the PR number is unknown")
+		}
+		be, err := backendWith[autoMerger](config, details, "auto-merge")
+		if err != nil {
+			return err
+		}
+		return retry.do(ctx, "Enabling auto-merge for "+res.Key, func(ctx context.Context) error {
+			return be.enableAutoMerge(ctx, details, &pullRequest{Number: res.Number, URL: res.URL}, details.AutoMerge)
+		})
+	}()
+	if err != nil {
+		log.Printf("Warning: failed to enable auto-merge for %s: %v\n", res.Key, err)
+		res.AutoMerge, res.AutoMergeError = autoMergeFailed, err.Error()
+		return
+	}
+	res.AutoMerge = autoMergeEnabled
+}
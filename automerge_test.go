@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreatePullRequestEnablesAutoMerge(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.errors["auto-merge org/b"] = withClass(classValidation, fmt.Errorf("auto-merge is not allowed for this repository"))
+
+	config := &Config{Output: outputJSON, Repos: map[string]Repo{
+		"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "T", AutoMerge: mergeMethodSquash},
+		"b": {Repo: "org/b", Base: "main", Head: "dev", Title: "T", AutoMerge: mergeMethodMerge},
+		"c": {Repo: "org/c", Base: "main", Head: "dev", Title: "T"},
+	}}
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, false) })
+	if err != nil {
+		t.Fatalf("Expected a failure to enable auto-merge not to fail the run, got %v", err)
+	}
+
+	var report struct{ Results []result }
+	json.Unmarshal([]byte(output), &report)
+	byKey := map[string]result{}
+	for _, res := range report.Results {
+		byKey[res.Key] = res
+	}
+	if res := byKey["a"]; res.Status != statusCreated || res.AutoMerge != autoMergeEnabled || be.autoMerge[res.Number] != mergeMethodSquash {
+		t.Errorf("Expected auto-merge to be enabled for a, got %+v", res)
+	}
+	if res := byKey["b"]; res.Status != statusCreated || res.AutoMerge != autoMergeFailed || !strings.Contains(res.AutoMergeError, "not allowed") {
+		t.Errorf("Expected b to be created with a failed auto-merge, got %+v", res)
+	}
+	if res := byKey["c"]; res.AutoMerge != "" {
+		t.Errorf("Expected no auto-merge for c, got %+v", res)
+	}
+}
+
+func TestCreatePullRequestInvalidAutoMerge(t *testing.T) {
+	be := useMemoryBackend(t)
+	config := &Config{Repos: map[string]Repo{"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "T", AutoMerge: "yes"}}}
+	if err := createPullRequest(config, false); err == nil || !strings.Contains(err.Error(), "invalid auto_merge") {
+		t.Errorf("Expected an invalid auto_merge error, got %v", err)
+	}
+	if len(be.calls) != 0 {
+		t.Errorf("Expected nothing to be created, got %v", be.calls)
+	}
+}
+
+func TestGitHubBackendEnableAutoMerge(t *testing.T) {
+	var graphqlBody map[string]interface{}
+	graphqlError := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/r/pulls/3":
+			fmt.Fprint(w, `{"number": 3, "node_id": "PR_abc"}`)
+		case "/graphql":
+			json.NewDecoder(r.Body).Decode(&graphqlBody)
+			if graphqlError != "" {
+				fmt.Fprint(w, graphqlError)
+				return
+			}
+			fmt.Fprint(w, `{"data": {"enablePullRequestAutoMerge": {"clientMutationId": null}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	be := newGitHubBackend(server.URL, "secret")
+	ctx := context.Background()
+	if err := be.enableAutoMerge(ctx, Repo{Repo: "org/r"}, &pullRequest{Number: 3}, mergeMethodSquash); err != nil {
+		t.Fatalf("enableAutoMerge failed: %v", err)
+	}
+	if vars := fmt.Sprint(graphqlBody["variables"]); vars != "map[id:PR_abc method:SQUASH]" {
+		t.Errorf("Unexpected GraphQL variables: %s", vars)
+	}
+
+	graphqlError = `{"data": null, "errors": [{"type": "UNPROCESSABLE", "message": "Pull request Auto merge is not allowed for this repository"}]}`
+	err := be.enableAutoMerge(ctx, Repo{Repo: "org/r"}, &pullRequest{Number: 3}, mergeMethodSquash)
+	if classOf(err) != classValidation || !strings.Contains(err.Error(), "Auto merge is not allowed") {
+		t.Errorf("Expected a validation error from GraphQL, got %v", err)
+	}
+
+	if url := newGitHubBackend("https://ghe.example.com/api/v3", "").graphqlURL; url != "https://ghe.example.com/api/graphql" {
+		t.Errorf("Unexpected GitHub Enterprise GraphQL URL: %s", url)
+	}
+}
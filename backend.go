@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// pullRequest is a pull (or merge) request as reported by a forge
+type pullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// backend is a forge that pull requests can be created on.
+// The orchestration in createPullRequest only talks to forges through this interface.
+type backend interface {
+	// describeCreate returns a human-readable form of the create request, used by dry runs
+	describeCreate(details Repo) string
+	// createPullRequest opens a pull request for details. It may return both a pull request
+	// and an error when the pull request was opened but a follow-up step failed.
+	createPullRequest(ctx context.Context, details Repo) (*pullRequest, error)
+	// findPullRequest returns the open pull request for the repo, base and head of details, or nil
+	findPullRequest(ctx context.Context, details Repo) (*pullRequest, error)
+	// updatePullRequest makes pr match the title, body, labels, assignees and reviewers of details
+	updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error
+	// closePullRequest closes pr without merging it
+	closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error
+}
+
+// Backends for GitHub, selected with --backend or `backend:`
+const (
+	backendGH  = "gh"
+	backendAPI = "api"
+)
+
+// validateBackend checks the value of `backend` / --backend
+func validateBackend(name string) error {
+	switch name {
+	case "", backendGH, backendAPI:
+		return nil
+	}
+	return fmt.Errorf("invalid backend %q, expected %q or %q", name, backendGH, backendAPI)
+}
+
+// Providers selectable per entry with `provider:`
+const (
+	providerGitHub    = "github"
+	providerGitLab    = "gitlab"
+	providerGitea     = "gitea"
+	providerForgejo   = "forgejo"
+	providerBitbucket = "bitbucket"
+)
+
+// fieldChecker is implemented by backends that cannot represent every Repo field
+type fieldChecker interface {
+	// unsupportedFields lists the fields set in details that the forge has no equivalent for
+	unsupportedFields(details Repo) []string
+}
+
+// errUnsupportedFields reports configured fields a provider cannot apply
+func errUnsupportedFields(provider string, fields []string) error {
+	return withClass(classValidation, fmt.Errorf("%s not supported by provider %q", strings.Join(fields, ", "), provider))
+}
+
+// checkSupportedFields fails when details sets fields the backend cannot apply,
+// so they are reported instead of being silently dropped
+func checkSupportedFields(be backend, details Repo) error {
+	checker, ok := be.(fieldChecker)
+	if !ok {
+		return nil
+	}
+	if fields := checker.unsupportedFields(details); len(fields) > 0 {
+		return errUnsupportedFields(providerName(details), fields)
+	}
+	return nil
+}
+
+// errUnsupportedOperation reports an operation the provider of details has no support for
+func errUnsupportedOperation(details Repo, operation string) error {
+	return withClass(classValidation, &unsupportedOperationError{operation: operation, provider: providerName(details)})
+}
+
+// unsupportedOperationError is the error of an operation the backend of a provider does not implement
+type unsupportedOperationError struct {
+	operation string
+	provider  string
+}
+
+func (e *unsupportedOperationError) Error() string {
+	return fmt.Sprintf("%s is not supported by provider %q", e.operation, e.provider)
+}
+
+// backendWith returns the backend of details as T, an optional interface needed for operation
+func backendWith[T any](config *Config, details Repo, operation string) (T, error) {
+	var impl T
+	be, err := backendFor(config, details)
+	if err != nil {
+		return impl, err
+	}
+	impl, ok := be.(T)
+	if !ok {
+		return impl, errUnsupportedOperation(details, operation)
+	}
+	return impl, nil
+}
+
+// providerName returns the provider of details, defaulting to GitHub
+func providerName(details Repo) string {
+	if details.Provider == "" {
+		return providerGitHub
+	}
+	return details.Provider
+}
+
+// mockBackend replaces every backend when set, to allow tests without a forge
+var mockBackend backend
+
+// backendFor returns the backend responsible for a repository entry
+func backendFor(config *Config, details Repo) (backend, error) {
+	if mockBackend != nil {
+		return mockBackend, nil
+	}
+
+	switch details.Provider {
+	case "", providerGitHub:
+		if config.Backend == backendAPI {
+			return githubAPIBackendFor(details.Host)
+		}
+		return ghBackend{host: details.Host}, nil
+	case providerGitLab:
+		return gitlabBackendFor(details.Host)
+	case providerGitea, providerForgejo:
+		return giteaBackendFor(details.Host)
+	case providerBitbucket:
+		return bitbucketBackendFor(details.Host)
+	}
+	return nil, withClass(classValidation, fmt.Errorf("unknown provider %q", details.Provider))
+}
+
+var (
+	backendCacheMu sync.Mutex
+	backendCache   = map[string]backend{}
+)
+
+// cachedBackend returns the backend stored under key, building it on first use.
+// Sharing one backend per host lets all workers share one view of the rate limit.
+func cachedBackend(key string, build func() (backend, error)) (backend, error) {
+	backendCacheMu.Lock()
+	defer backendCacheMu.Unlock()
+
+	if be, ok := backendCache[key]; ok {
+		return be, nil
+	}
+	be, err := build()
+	if err != nil {
+		return nil, err
+	}
+	backendCache[key] = be
+	return be, nil
+}
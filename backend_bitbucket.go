@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// bitbucketBackend opens pull requests through the Bitbucket Server (Data Center) REST API
+type bitbucketBackend struct {
+	client *apiClient
+}
+
+// newBitbucketBackend returns a backend for the API at baseURL (ending in /rest/api/1.0) authenticated with token
+func newBitbucketBackend(baseURL, token string) *bitbucketBackend {
+	return &bitbucketBackend{client: newAPIClient(baseURL, func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	})}
+}
+
+// bitbucketBackendFor returns the shared backend for a Bitbucket Server host
+func bitbucketBackendFor(host string) (backend, error) {
+	if host == "" {
+		return nil, withClass(classValidation, fmt.Errorf("provider %q requires `host`", providerBitbucket))
+	}
+	return cachedBackend(providerBitbucket+"|"+host, func() (backend, error) {
+		be := newBitbucketBackend("https://"+host+"/rest/api/1.0", os.Getenv("BITBUCKET_TOKEN"))
+		if os.Getenv("BITBUCKET_TOKEN") == "" {
+			be.client.authErr = withClass(classAuth, fmt.Errorf("no Bitbucket token found: set BITBUCKET_TOKEN"))
+		}
+		return be, nil
+	})
+}
+
+// unsupportedFields reports the fields Bitbucket Server pull requests have no equivalent for
+func (b *bitbucketBackend) unsupportedFields(details Repo) []string {
+	var fields []string
+	if len(details.Labels) > 0 {
+		fields = append(fields, "labels")
+	}
+	if len(details.Assignees) > 0 {
+		fields = append(fields, "assignees")
+	}
+	if _, teams := splitReviewers(details.Reviewers); len(teams) > 0 {
+		fields = append(fields, "team reviewers")
+	}
+	return fields
+}
+
+// repoPath returns the API path of details.Repo ("PROJECT/repo-slug")
+func (b *bitbucketBackend) repoPath(details Repo) string {
+	project, slug, _ := strings.Cut(details.Repo, "/")
+	return "/projects/" + url.PathEscape(project) + "/repos/" + url.PathEscape(slug)
+}
+
+// pullRequestsPath returns the API path of the pull requests of details.Repo
+func (b *bitbucketBackend) pullRequestsPath(details Repo) string {
+	return b.repoPath(details) + "/pull-requests"
+}
+
+// preflight checks an entry against the Bitbucket Server API: the repository exists and is not archived,
+// the branches exist and head is ahead of base, and the reviewers exist. Push access cannot be read
+// without admin rights, so it is not checked.
+func (b *bitbucketBackend) preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error) {
+	var repo struct {
+		Archived bool `json:"archived"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, b.repoPath(details), nil, &repo); err != nil {
+		if classOf(err) == classNotFound {
+			return []string{fmt.Sprintf("repository %s does not exist or is not accessible", details.Repo)}, nil
+		}
+		return nil, err
+	}
+
+	var problems []string
+	if fields := b.unsupportedFields(details); len(fields) > 0 {
+		problems = append(problems, errUnsupportedFields(providerName(details), fields).Error())
+	}
+	if repo.Archived {
+		problems = append(problems, fmt.Sprintf("repository %s is archived", details.Repo))
+	}
+
+	branches := []string{details.Base}
+	if checkHead {
+		branches = append(branches, details.Head)
+	}
+	before := len(problems)
+	for _, branch := range branches {
+		// Branches are only listed, filtered by a substring of their name
+		var page struct {
+			Values []struct {
+				DisplayID string `json:"displayId"`
+			} `json:"values"`
+		}
+		query := url.Values{"filterText": {branch}, "limit": {"100"}}
+		if err := b.client.do(ctx, http.MethodGet, b.repoPath(details)+"/branches?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		found := false
+		for _, value := range page.Values {
+			found = found || value.DisplayID == branch
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("branch %s does not exist", branch))
+		}
+	}
+	if checkHead && len(problems) == before {
+		var page struct {
+			Values []struct{} `json:"values"`
+		}
+		query := url.Values{"since": {"refs/heads/" + details.Base}, "until": {"refs/heads/" + details.Head}, "limit": {"1"}}
+		if err := b.client.do(ctx, http.MethodGet, b.repoPath(details)+"/commits?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		if len(page.Values) == 0 {
+			problems = append(problems, fmt.Sprintf("%s has no commits ahead of %s", details.Head, details.Base))
+		}
+	}
+
+	users, _ := splitReviewers(details.Reviewers)
+	for _, user := range users {
+		err := b.client.do(ctx, http.MethodGet, "/users/"+url.PathEscape(user), nil, nil)
+		if err := checkExists(&problems, err, fmt.Sprintf("user %s does not exist", user)); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+// bitbucketRef builds a ref object for a branch of details.Repo
+func bitbucketRef(details Repo, branch string) map[string]interface{} {
+	project, slug, _ := strings.Cut(details.Repo, "/")
+	return map[string]interface{}{
+		"id": "refs/heads/" + branch,
+		"repository": map[string]interface{}{
+			"slug":    slug,
+			"project": map[string]string{"key": project},
+		},
+	}
+}
+
+// bitbucketReviewers builds the reviewers list of a pull request
+func bitbucketReviewers(details Repo) []map[string]interface{} {
+	reviewers := make([]map[string]interface{}, 0, len(details.Reviewers))
+	for _, name := range details.Reviewers {
+		reviewers = append(reviewers, map[string]interface{}{"user": map[string]string{"name": name}})
+	}
+	return reviewers
+}
+
+// bitbucketPull is the subset of the Bitbucket Server pull request resource used here
+type bitbucketPull struct {
+	ID      int    `json:"id"`
+	Version int    `json:"version"`
+	State   string `json:"state"` // OPEN, MERGED or DECLINED
+	Draft   bool   `json:"draft"`
+	FromRef struct {
+		LatestCommit string `json:"latestCommit"`
+	} `json:"fromRef"`
+	ToRef struct {
+		ID string `json:"id"`
+	} `json:"toRef"`
+	Reviewers []struct {
+		Status string `json:"status"` // APPROVED, NEEDS_WORK or UNAPPROVED
+		User   struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	} `json:"reviewers"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+func (p bitbucketPull) pullRequest() *pullRequest {
+	pr := &pullRequest{Number: p.ID}
+	if len(p.Links.Self) > 0 {
+		pr.URL = p.Links.Self[0].Href
+	}
+	return pr
+}
+
+func (b *bitbucketBackend) describeCreate(details Repo) string {
+	return fmt.Sprintf("POST %s%s (title: %q, fromRef: %q, toRef: %q, draft: %t, reviewers: %v)",
+		b.client.baseURL, b.pullRequestsPath(details), details.Title, "refs/heads/"+details.Head, "refs/heads/"+details.Base, details.Draft, details.Reviewers)
+}
+
+func (b *bitbucketBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	if err := checkSupportedFields(b, details); err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"title":       details.Title,
+		"description": details.Body,
+		"fromRef":     bitbucketRef(details, details.Head),
+		"toRef":       bitbucketRef(details, details.Base),
+		"reviewers":   bitbucketReviewers(details),
+	}
+	if details.Draft {
+		body["draft"] = true
+	}
+
+	var created bitbucketPull
+	if err := b.client.do(ctx, http.MethodPost, b.pullRequestsPath(details), body, &created); err != nil {
+		return nil, err
+	}
+	return created.pullRequest(), nil
+}
+
+// getPull fetches a pull request, which is needed for its current version
+func (b *bitbucketBackend) getPull(ctx context.Context, details Repo, pr *pullRequest) (bitbucketPull, error) {
+	var pull bitbucketPull
+	err := b.client.do(ctx, http.MethodGet, b.pullRequestsPath(details)+"/"+strconv.Itoa(pr.Number), nil, &pull)
+	return pull, err
+}
+
+func (b *bitbucketBackend) findPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	query := url.Values{"state": {"OPEN"}, "direction": {"OUTGOING"}, "at": {"refs/heads/" + details.Head}, "limit": {"100"}}
+	var page struct {
+		Values []bitbucketPull `json:"values"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, b.pullRequestsPath(details)+"?"+query.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	for _, pull := range page.Values {
+		if pull.ToRef.ID == "refs/heads/"+details.Base {
+			return pull.pullRequest(), nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *bitbucketBackend) updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	if err := checkSupportedFields(b, details); err != nil {
+		return err
+	}
+	pull, err := b.getPull(ctx, details, pr)
+	if err != nil {
+		return err
+	}
+
+	// The reviewers replace those of the PR, so the current ones are kept, along with their approvals
+	var current []string
+	for _, reviewer := range pull.Reviewers {
+		current = append(current, reviewer.User.Name)
+	}
+	details.Reviewers = mergeList(current, details.Reviewers, listMergeAppend)
+
+	body := map[string]interface{}{
+		"version":     pull.Version,
+		"title":       details.Title,
+		"description": details.Body,
+		"reviewers":   bitbucketReviewers(details),
+	}
+	return b.client.do(ctx, http.MethodPut, b.pullRequestsPath(details)+"/"+strconv.Itoa(pr.Number), body, nil)
+}
+
+func (b *bitbucketBackend) closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	pull, err := b.getPull(ctx, details, pr)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/%d/decline?version=%d", b.pullRequestsPath(details), pr.Number, pull.Version)
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{}, nil)
+}
+
+// bitbucketMergeStrategies maps merge methods to the merge strategies of Bitbucket Server,
+// which must be enabled in the repository
+var bitbucketMergeStrategies = map[string]string{
+	mergeMethodMerge:  "no-ff",
+	mergeMethodSquash: "squash",
+	mergeMethodRebase: "rebase-ff-only",
+}
+
+func (b *bitbucketBackend) mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	pull, err := b.getPull(ctx, details, pr)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/%d/merge?version=%d", b.pullRequestsPath(details), pr.Number, pull.Version)
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{"strategyId": bitbucketMergeStrategies[method]}, nil)
+}
+
+func (b *bitbucketBackend) commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error {
+	path := b.pullRequestsPath(details) + "/" + strconv.Itoa(pr.Number) + "/comments"
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{"text": body}, nil)
+}
+
+func (b *bitbucketBackend) listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error) {
+	var bodies []string
+	for start := 0; ; {
+		var page struct {
+			Values []struct {
+				Comment *struct {
+					Text string `json:"text"`
+				} `json:"comment"`
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+		path := fmt.Sprintf("%s/%d/activities?limit=100&start=%d", b.pullRequestsPath(details), pr.Number, start)
+		if err := b.client.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, activity := range page.Values {
+			if activity.Comment != nil {
+				bodies = append(bodies, activity.Comment.Text)
+			}
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			return bodies, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+func (b *bitbucketBackend) pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error) {
+	query := url.Values{"state": {"ALL"}, "direction": {"OUTGOING"}, "at": {"refs/heads/" + details.Head}, "order": {"NEWEST"}, "limit": {"100"}}
+	var page struct {
+		Values []bitbucketPull `json:"values"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, b.pullRequestsPath(details)+"?"+query.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	var pull *bitbucketPull
+	for i := range page.Values {
+		if page.Values[i].ToRef.ID == "refs/heads/"+details.Base {
+			pull = &page.Values[i]
+			break
+		}
+	}
+	if pull == nil {
+		return nil, nil
+	}
+
+	status := &pullRequestStatus{pullRequest: *pull.pullRequest(), State: prStateOpen, Mergeable: "unknown"}
+	switch {
+	case pull.State == "MERGED":
+		status.State = prStateMerged
+	case pull.State != "OPEN":
+		status.State = prStateClosed
+	case pull.Draft:
+		status.State = prStateDraft
+	}
+
+	// Reviewers are only those that were added, required approvals are enforced by merge checks
+	for _, reviewer := range pull.Reviewers {
+		switch reviewer.Status {
+		case "NEEDS_WORK":
+			status.ReviewDecision = reviewChangesRequested
+		case "APPROVED":
+			if status.ReviewDecision == "" {
+				status.ReviewDecision = reviewApproved
+			}
+		}
+	}
+	if len(pull.Reviewers) > 0 && status.ReviewDecision == "" {
+		status.ReviewDecision = reviewRequired
+	}
+
+	if pull.State == "OPEN" {
+		var merge struct {
+			Conflicted bool `json:"conflicted"`
+		}
+		if err := b.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d/merge", b.pullRequestsPath(details), pull.ID), nil, &merge); err != nil {
+			return nil, fmt.Errorf("failed to check mergeability: %w", err)
+		}
+		status.Mergeable = "mergeable"
+		if merge.Conflicted {
+			status.Mergeable = "conflicting"
+		}
+	}
+
+	checks, err := b.buildStatuses(ctx, pull.FromRef.LatestCommit)
+	if err != nil {
+		return nil, err
+	}
+	status.Checks = rollupChecks(checks)
+	return status, nil
+}
+
+// buildStatuses returns the build statuses of a commit, which are served by the build status API next to the core REST API
+func (b *bitbucketBackend) buildStatuses(ctx context.Context, commit string) ([]checkState, error) {
+	base := strings.TrimSuffix(b.client.baseURL, "/rest/api/1.0") + "/rest/build-status/1.0/commits/" + url.PathEscape(commit)
+	var checks []checkState
+	for start := 0; ; {
+		var page struct {
+			Values []struct {
+				State string `json:"state"` // SUCCESSFUL, FAILED or INPROGRESS
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+		if err := b.client.do(ctx, http.MethodGet, fmt.Sprintf("%s?limit=100&start=%d", base, start), nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to get build statuses: %w", err)
+		}
+		for _, build := range page.Values {
+			state := checksSuccess
+			switch build.State {
+			case "FAILED":
+				state = checksFailure
+			case "INPROGRESS":
+				state = checksPending
+			}
+			checks = append(checks, checkState{State: state})
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			return checks, nil
+		}
+		start = page.NextPageStart
+	}
+}
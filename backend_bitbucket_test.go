@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBitbucket is a minimal stand-in for the Bitbucket Server REST API
+type fakeBitbucket struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+}
+
+func newFakeBitbucket(t *testing.T) (*fakeBitbucket, *httptest.Server) {
+	t.Helper()
+	fake := &fakeBitbucket{bodies: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, key+"?"+r.URL.RawQuery)
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.bodies[key] = body
+
+	const pull = `{"id": 3, "version": 5, "toRef": {"id": "refs/heads/main"}, "reviewers": [{"status": "APPROVED", "user": {"name": "carol"}}], "links": {"self": [{"href": "https://bitbucket.test/projects/PRJ/repos/svc/pull-requests/3"}]}}`
+	switch key {
+	case "POST /rest/api/1.0/projects/PRJ/repos/svc/pull-requests":
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, pull)
+	case "GET /rest/api/1.0/projects/PRJ/repos/svc/pull-requests":
+		fmt.Fprint(w, `{"values": [`+pull+`]}`)
+	case "GET /rest/api/1.0/projects/PRJ/repos/svc/pull-requests/3":
+		fmt.Fprint(w, pull)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+func TestBitbucketBackend(t *testing.T) {
+	fake, server := newFakeBitbucket(t)
+	be := newBitbucketBackend(server.URL+"/rest/api/1.0", "secret")
+	ctx := context.Background()
+
+	details := Repo{Repo: "PRJ/svc", Base: "main", Head: "dev", Title: "T", Body: "B", Draft: true, Reviewers: []string{"bob"}}
+	pr, err := be.createPullRequest(ctx, details)
+	if err != nil {
+		t.Fatalf("createPullRequest failed: %v", err)
+	}
+	if pr.Number != 3 || !strings.HasSuffix(pr.URL, "/pull-requests/3") {
+		t.Errorf("Unexpected PR: %+v", pr)
+	}
+	body := fake.bodies["POST /rest/api/1.0/projects/PRJ/repos/svc/pull-requests"]
+	if body["description"] != "B" || body["draft"] != true || !strings.Contains(fmt.Sprint(body["fromRef"]), "refs/heads/dev") {
+		t.Errorf("Unexpected create body: %v", body)
+	}
+	if fmt.Sprint(body["reviewers"]) != "[map[user:map[name:bob]]]" {
+		t.Errorf("Unexpected reviewers: %v", body["reviewers"])
+	}
+
+	found, err := be.findPullRequest(ctx, details)
+	if err != nil || found == nil || found.Number != 3 {
+		t.Errorf("Expected to find PR 3, got %v, %v", found, err)
+	}
+	if found, _ := be.findPullRequest(ctx, Repo{Repo: "PRJ/svc", Base: "release", Head: "dev"}); found != nil {
+		t.Errorf("Expected no PR for another base, got %+v", found)
+	}
+
+	// The reviewers of the PR are kept, since the update replaces them
+	if err := be.updatePullRequest(ctx, details, pr); err != nil {
+		t.Fatalf("updatePullRequest failed: %v", err)
+	}
+	body = fake.bodies["PUT /rest/api/1.0/projects/PRJ/repos/svc/pull-requests/3"]
+	if body["version"] != 5.0 || fmt.Sprint(body["reviewers"]) != "[map[user:map[name:carol]] map[user:map[name:bob]]]" {
+		t.Errorf("Unexpected update body: %v", body)
+	}
+
+	if err := be.closePullRequest(ctx, details, pr); err != nil {
+		t.Fatalf("closePullRequest failed: %v", err)
+	}
+	if last := fake.requests[len(fake.requests)-1]; last != "POST /rest/api/1.0/projects/PRJ/repos/svc/pull-requests/3/decline?version=5" {
+		t.Errorf("Expected decline with the current version, got %s", last)
+	}
+}
+
+func TestBitbucketUnsupportedFields(t *testing.T) {
+	fake, server := newFakeBitbucket(t)
+	originalMockBackend := mockBackend
+	defer func() { mockBackend = originalMockBackend }()
+	mockBackend = newBitbucketBackend(server.URL+"/rest/api/1.0", "secret")
+
+	config := &Config{Output: outputJSON, Repos: map[string]Repo{
+		"svc": {Repo: "PRJ/svc", Base: "main", Head: "dev", Title: "T", Body: "B", Labels: []string{"l"}, Reviewers: []string{"org/team"}},
+	}}
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, true) })
+	if err == nil {
+		t.Fatal("Expected unsupported fields to fail the entry, even in a dry run")
+	}
+
+	var report struct{ Results []result }
+	json.Unmarshal([]byte(output), &report)
+	if len(report.Results) != 1 || report.Results[0].ErrorClass != classValidation ||
+		!strings.Contains(report.Results[0].Error, "labels, team reviewers not supported") {
+		t.Errorf("Unexpected report: %s", output)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no API calls, got %v", fake.requests)
+	}
+}
+
+func TestBackendForBitbucketRequiresHost(t *testing.T) {
+	if _, err := backendFor(&Config{}, Repo{Provider: providerBitbucket}); classOf(err) != classValidation {
+		t.Errorf("Expected validation error without host, got %v", err)
+	}
+	be, err := backendFor(&Config{}, Repo{Provider: providerBitbucket, Host: "bitbucket.example.com"})
+	if err != nil {
+		t.Fatalf("backendFor failed: %v", err)
+	}
+	if desc := be.describeCreate(Repo{Repo: "PRJ/svc", Base: "main", Head: "dev"}); !strings.HasPrefix(desc, "POST https://bitbucket.example.com/rest/api/1.0/projects/PRJ/repos/svc/pull-requests") {
+		t.Errorf("Unexpected dry run description: %s", desc)
+	}
+}
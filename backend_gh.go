@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ghBackend talks to GitHub through the gh CLI
+type ghBackend struct {
+	host string // GitHub Enterprise Server host of the entries, empty for gh's default host
+}
+
+// repo returns the --repo argument for details, which names the host when it is not gh's default
+func (b ghBackend) repo(details Repo) string {
+	if b.host == "" {
+		return details.Repo
+	}
+	return b.host + "/" + details.Repo
+}
+
+// api returns the arguments of a `gh api` call on the host of the backend
+func (b ghBackend) api(args ...string) []string {
+	cmd := []string{"gh", "api"}
+	if b.host != "" {
+		cmd = append(cmd, "--hostname", b.host)
+	}
+	return append(cmd, args...)
+}
+
+// createArgs returns the `gh pr create` arguments for details.
+// When quote is true every value is quoted for display.
+func (b ghBackend) createArgs(details Repo, quote bool) []string {
+	value := func(s string) string {
+		if quote {
+			return fmt.Sprintf("%q", s)
+		}
+		return s
+	}
+
+	args := []string{"gh", "pr", "create"}
+	if details.Draft {
+		args = append(args, "--draft")
+	}
+	args = append(args,
+		"--title", value(details.Title),
+		"--body", value(details.Body),
+		"--base", value(details.Base),
+		"--head", value(details.Head))
+	for _, label := range details.Labels {
+		args = append(args, "--label", value(label))
+	}
+	for _, assignee := range details.Assignees {
+		args = append(args, "--assignee", value(assignee))
+	}
+	for _, reviewer := range details.Reviewers {
+		args = append(args, "--reviewer", value(reviewer))
+	}
+	args = append(args, "--repo", value(b.repo(details)))
+	return args
+}
+
+func (b ghBackend) describeCreate(details Repo) string {
+	return strings.Join(b.createArgs(details, true), " ")
+}
+
+func (b ghBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	out, err := runCommandOutput(ctx, b.createArgs(details, false)...)
+	if err != nil {
+		return nil, err
+	}
+	url, number := parsePullRequestURL(string(out))
+	return &pullRequest{Number: number, URL: url}, nil
+}
+
+func (b ghBackend) findPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	out, err := runCommandOutput(ctx, "gh", "pr", "list",
+		"--repo", b.repo(details),
+		"--base", details.Base,
+		"--head", details.Head,
+		"--state", "open",
+		"--json", "number,url",
+		"--limit", "1")
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	var prs []pullRequest
+	if err := json.Unmarshal(out, &prs); err != nil {
+		return nil, fmt.Errorf("failed to parse existing PR lookup output: %w", err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+func (b ghBackend) updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	args := []string{"gh", "pr", "edit", strconv.Itoa(pr.Number),
+		"--repo", b.repo(details),
+		"--title", details.Title,
+		"--body", details.Body}
+	for _, label := range details.Labels {
+		args = append(args, "--add-label", label)
+	}
+	for _, assignee := range details.Assignees {
+		args = append(args, "--add-assignee", assignee)
+	}
+	for _, reviewer := range details.Reviewers {
+		args = append(args, "--add-reviewer", reviewer)
+	}
+	return runCommand(ctx, args...)
+}
+
+func (b ghBackend) closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	return runCommand(ctx, "gh", "pr", "close", strconv.Itoa(pr.Number), "--repo", b.repo(details))
+}
+
+func (b ghBackend) pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error) {
+	out, err := runCommandOutput(ctx, "gh", "pr", "list",
+		"--repo", b.repo(details),
+		"--base", details.Base,
+		"--head", details.Head,
+		"--state", "all",
+		"--json", "number,url,state,isDraft,reviewDecision,mergeable,statusCheckRollup",
+		"--limit", "1")
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	var prs []struct {
+		Number            int          `json:"number"`
+		URL               string       `json:"url"`
+		State             string       `json:"state"`
+		IsDraft           bool         `json:"isDraft"`
+		ReviewDecision    string       `json:"reviewDecision"`
+		Mergeable         string       `json:"mergeable"`
+		StatusCheckRollup []checkState `json:"statusCheckRollup"`
+	}
+	if err := json.Unmarshal(out, &prs); err != nil {
+		return nil, fmt.Errorf("failed to parse PR status output: %w", err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+
+	pr := prs[0]
+	status := &pullRequestStatus{
+		pullRequest:    pullRequest{Number: pr.Number, URL: pr.URL},
+		State:          strings.ToLower(pr.State),
+		ReviewDecision: strings.ToLower(pr.ReviewDecision),
+		Checks:         rollupChecks(pr.StatusCheckRollup),
+		Mergeable:      strings.ToLower(pr.Mergeable),
+	}
+	if pr.IsDraft && status.State == prStateOpen {
+		status.State = prStateDraft
+	}
+	return status, nil
+}
+
+func (b ghBackend) mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	return runCommand(ctx, "gh", "pr", "merge", strconv.Itoa(pr.Number), "--repo", b.repo(details), "--"+method)
+}
+
+func (b ghBackend) deleteBranch(ctx context.Context, details Repo, branch string) error {
+	return runCommand(ctx, b.api("--method", "DELETE", "repos/"+details.Repo+"/git/refs/heads/"+branch)...)
+}
+
+func (b ghBackend) commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error {
+	return runCommand(ctx, "gh", "pr", "comment", strconv.Itoa(pr.Number), "--repo", b.repo(details), "--body", body)
+}
+
+func (b ghBackend) listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error) {
+	out, err := runCommandOutput(ctx, "gh", "pr", "view", strconv.Itoa(pr.Number), "--repo", b.repo(details), "--json", "comments")
+	if err != nil {
+		return nil, err
+	}
+
+	var view struct {
+		Comments []struct {
+			Body string `json:"body"`
+		} `json:"comments"`
+	}
+	if err := json.Unmarshal(out, &view); err != nil {
+		return nil, fmt.Errorf("failed to parse PR comments: %w", err)
+	}
+	bodies := make([]string, 0, len(view.Comments))
+	for _, comment := range view.Comments {
+		bodies = append(bodies, comment.Body)
+	}
+	return bodies, nil
+}
+
+func (b ghBackend) enableAutoMerge(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	return runCommand(ctx, "gh", "pr", "merge", strconv.Itoa(pr.Number), "--repo", b.repo(details), "--auto", "--"+method)
+}
+
+func (b ghBackend) listRepositories(ctx context.Context, owner string) ([]repository, error) {
+	// gh repo list has no flag for the host
+	var env []string
+	if b.host != "" {
+		env = []string{"GH_HOST=" + b.host}
+	}
+	out, err := runCommandIn(ctx, "", env, "gh", "repo", "list", owner,
+		"--limit", "4000",
+		"--json", "nameWithOwner,repositoryTopics,primaryLanguage,visibility,isArchived,isFork,defaultBranchRef")
+	if err != nil {
+		return nil, err
+	}
+
+	var listed []struct {
+		NameWithOwner    string `json:"nameWithOwner"`
+		RepositoryTopics []struct {
+			Name string `json:"name"`
+		} `json:"repositoryTopics"`
+		PrimaryLanguage *struct {
+			Name string `json:"name"`
+		} `json:"primaryLanguage"`
+		Visibility       string `json:"visibility"`
+		IsArchived       bool   `json:"isArchived"`
+		IsFork           bool   `json:"isFork"`
+		DefaultBranchRef *struct {
+			Name string `json:"name"`
+		} `json:"defaultBranchRef"`
+	}
+	if err := json.Unmarshal(out, &listed); err != nil {
+		return nil, fmt.Errorf("failed to parse repository list: %w", err)
+	}
+
+	repos := make([]repository, 0, len(listed))
+	for _, l := range listed {
+		r := repository{FullName: l.NameWithOwner, Visibility: strings.ToLower(l.Visibility), Archived: l.IsArchived, Fork: l.IsFork}
+		for _, topic := range l.RepositoryTopics {
+			r.Topics = append(r.Topics, topic.Name)
+		}
+		if l.PrimaryLanguage != nil {
+			r.Language = l.PrimaryLanguage.Name
+		}
+		if l.DefaultBranchRef != nil {
+			r.DefaultBranch = l.DefaultBranchRef.Name
+		}
+		repos = append(repos, r)
+	}
+	return repos, nil
+}
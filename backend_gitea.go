@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// giteaDefaultHost is the host used for Gitea entries without `host`
+const giteaDefaultHost = "gitea.com"
+
+// giteaBackend opens pull requests through the Gitea (and Forgejo) REST API
+type giteaBackend struct {
+	client *apiClient
+}
+
+// newGiteaBackend returns a backend for the API at baseURL (ending in /api/v1) authenticated with token
+func newGiteaBackend(baseURL, token string) *giteaBackend {
+	return &giteaBackend{client: newAPIClient(baseURL, func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "token "+token)
+		}
+	})}
+}
+
+// giteaToken returns the token for Gitea or Forgejo from the environment
+func giteaToken() (string, error) {
+	for _, name := range []string{"GITEA_TOKEN", "FORGEJO_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
+	return "", withClass(classAuth, fmt.Errorf("no Gitea token found: set GITEA_TOKEN or FORGEJO_TOKEN"))
+}
+
+// giteaBackendFor returns the shared backend for a Gitea or Forgejo host
+func giteaBackendFor(host string) (backend, error) {
+	if host == "" {
+		host = giteaDefaultHost
+	}
+	return cachedBackend(providerGitea+"|"+host, func() (backend, error) {
+		token, err := giteaToken()
+		be := newGiteaBackend("https://"+host+"/api/v1", token)
+		be.client.authErr = err
+		return be, nil
+	})
+}
+
+// giteaPull is the subset of the Gitea pull request resource used here
+type giteaPull struct {
+	Number    int    `json:"number"`
+	HTMLURL   string `json:"html_url"`
+	Title     string `json:"title"`
+	State     string `json:"state"` // open or closed
+	Merged    bool   `json:"merged"`
+	Mergeable bool   `json:"mergeable"`
+	Base      struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+}
+
+func (p giteaPull) pullRequest() *pullRequest {
+	return &pullRequest{Number: p.Number, URL: p.HTMLURL}
+}
+
+// wipTitle marks the title as work in progress, which is how Gitea represents drafts
+func wipTitle(details Repo) string {
+	if details.Draft && !strings.HasPrefix(details.Title, "WIP:") {
+		return "WIP: " + details.Title
+	}
+	return details.Title
+}
+
+// isWIP reports whether a title marks a pull request as work in progress, with Gitea's default prefixes
+func isWIP(title string) bool {
+	upper := strings.ToUpper(title)
+	return strings.HasPrefix(upper, "WIP:") || strings.HasPrefix(upper, "[WIP]")
+}
+
+// repoLabels returns the IDs of the labels of details.Repo by name
+func (b *giteaBackend) repoLabels(ctx context.Context, details Repo) (map[string]int, error) {
+	var labels []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/labels?limit=200", nil, &labels); err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	byName := make(map[string]int, len(labels))
+	for _, label := range labels {
+		byName[label.Name] = label.ID
+	}
+	return byName, nil
+}
+
+// labelIDs resolves label names to the IDs the Gitea API expects
+func (b *giteaBackend) labelIDs(ctx context.Context, details Repo) ([]int, error) {
+	if len(details.Labels) == 0 {
+		return nil, nil
+	}
+	byName, err := b.repoLabels(ctx, details)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(details.Labels))
+	for _, name := range details.Labels {
+		id, ok := byName[name]
+		if !ok {
+			return nil, withClass(classValidation, fmt.Errorf("label %q does not exist in %s", name, details.Repo))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// preflight checks an entry against the Gitea API: the repository exists, is not archived and can be pushed to,
+// the branches exist and head is ahead of base, the labels exist, and assignees and reviewers are collaborators
+func (b *giteaBackend) preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error) {
+	repoPath := "/repos/" + details.Repo
+	var repo struct {
+		Archived    bool `json:"archived"`
+		Permissions *struct {
+			Push bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, repoPath, nil, &repo); err != nil {
+		if classOf(err) == classNotFound {
+			return []string{fmt.Sprintf("repository %s does not exist or is not accessible", details.Repo)}, nil
+		}
+		return nil, err
+	}
+
+	var problems []string
+	// A head given as "owner:branch" is in a fork, which is what is pushed to instead of the repository
+	_, _, fork := strings.Cut(details.Head, ":")
+	if repo.Archived {
+		problems = append(problems, fmt.Sprintf("repository %s is archived", details.Repo))
+	}
+	if repo.Permissions != nil && !repo.Permissions.Push && !fork {
+		problems = append(problems, fmt.Sprintf("you cannot push to %s", details.Repo))
+	}
+
+	before := len(problems)
+	branchErr := b.client.do(ctx, http.MethodGet, repoPath+"/branches/"+url.PathEscape(details.Base), nil, nil)
+	if err := checkExists(&problems, branchErr, fmt.Sprintf("branch %s does not exist", details.Base)); err != nil {
+		return nil, err
+	}
+	if checkHead && len(problems) == before {
+		// Like on GitHub, the comparison finds the head in a fork too
+		var compare struct {
+			TotalCommits int `json:"total_commits"`
+		}
+		err := b.client.do(ctx, http.MethodGet, repoPath+"/compare/"+url.PathEscape(details.Base)+"..."+url.PathEscape(details.Head), nil, &compare)
+		switch {
+		case classOf(err) == classNotFound:
+			problems = append(problems, fmt.Sprintf("branch %s does not exist", details.Head))
+		case err != nil:
+			return nil, err
+		case compare.TotalCommits == 0:
+			problems = append(problems, fmt.Sprintf("%s has no commits ahead of %s", details.Head, details.Base))
+		}
+	}
+
+	if len(details.Labels) > 0 {
+		labels, err := b.repoLabels(ctx, details)
+		if err != nil {
+			return nil, err
+		}
+		for _, label := range details.Labels {
+			if _, ok := labels[label]; !ok {
+				problems = append(problems, fmt.Sprintf("label %q does not exist", label))
+			}
+		}
+	}
+	for _, assignee := range details.Assignees {
+		err := b.client.do(ctx, http.MethodGet, repoPath+"/collaborators/"+url.PathEscape(assignee), nil, nil)
+		if err := checkExists(&problems, err, fmt.Sprintf("%s cannot be assigned", assignee)); err != nil {
+			return nil, err
+		}
+	}
+	for _, reviewer := range details.Reviewers {
+		if _, team, ok := strings.Cut(reviewer, "/"); ok {
+			err := b.client.do(ctx, http.MethodGet, repoPath+"/teams/"+url.PathEscape(team), nil, nil)
+			if err := checkExists(&problems, err, fmt.Sprintf("team %s has no access to %s", reviewer, details.Repo)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err := b.client.do(ctx, http.MethodGet, repoPath+"/collaborators/"+url.PathEscape(reviewer), nil, nil)
+		if err := checkExists(&problems, err, fmt.Sprintf("%s cannot review pull requests in %s", reviewer, details.Repo)); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+func (b *giteaBackend) describeCreate(details Repo) string {
+	return fmt.Sprintf("POST %s/repos/%s/pulls (title: %q, head: %q, base: %q, labels: %v, assignees: %v, reviewers: %v)",
+		b.client.baseURL, details.Repo, wipTitle(details), details.Head, details.Base, details.Labels, details.Assignees, details.Reviewers)
+}
+
+func (b *giteaBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	labels, err := b.labelIDs(ctx, details)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"title": wipTitle(details),
+		"body":  details.Body,
+		"head":  details.Head,
+		"base":  details.Base,
+	}
+	if len(labels) > 0 {
+		body["labels"] = labels
+	}
+	if len(details.Assignees) > 0 {
+		body["assignees"] = details.Assignees
+	}
+
+	var created giteaPull
+	if err := b.client.do(ctx, http.MethodPost, "/repos/"+details.Repo+"/pulls", body, &created); err != nil {
+		return nil, err
+	}
+
+	pr := created.pullRequest()
+	if err := b.requestReviewers(ctx, details, pr); err != nil {
+		return pr, fmt.Errorf("PR %s was created but: %w", pr.URL, err)
+	}
+	return pr, nil
+}
+
+// requestReviewers requests reviews from the users and `org/team` teams of details
+func (b *giteaBackend) requestReviewers(ctx context.Context, details Repo, pr *pullRequest) error {
+	if len(details.Reviewers) == 0 {
+		return nil
+	}
+	users, teams := splitReviewers(details.Reviewers)
+	path := fmt.Sprintf("/repos/%s/pulls/%d/requested_reviewers", details.Repo, pr.Number)
+	if err := b.client.do(ctx, http.MethodPost, path, map[string][]string{"reviewers": users, "team_reviewers": teams}, nil); err != nil {
+		return fmt.Errorf("failed to request reviewers: %w", err)
+	}
+	return nil
+}
+
+func (b *giteaBackend) findPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	for page := 1; ; page++ {
+		query := url.Values{"state": {"open"}, "limit": {"50"}, "page": {strconv.Itoa(page)}}
+		var pulls []giteaPull
+		if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/pulls?"+query.Encode(), nil, &pulls); err != nil {
+			return nil, err
+		}
+		for _, pull := range pulls {
+			if pull.Base.Ref == details.Base && pull.Head.Ref == details.Head {
+				return pull.pullRequest(), nil
+			}
+		}
+		if len(pulls) < 50 {
+			return nil, nil
+		}
+	}
+}
+
+func (b *giteaBackend) updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	// The update replaces labels and assignees, so the ones the PR already has are kept
+	if len(details.Labels) > 0 || len(details.Assignees) > 0 {
+		var current giteaPull
+		if err := b.client.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", details.Repo, pr.Number), nil, &current); err != nil {
+			return err
+		}
+		var labels, assignees []string
+		for _, label := range current.Labels {
+			labels = append(labels, label.Name)
+		}
+		for _, assignee := range current.Assignees {
+			assignees = append(assignees, assignee.Login)
+		}
+		details.Labels = mergeList(labels, details.Labels, listMergeAppend)
+		details.Assignees = mergeList(assignees, details.Assignees, listMergeAppend)
+	}
+	labels, err := b.labelIDs(ctx, details)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"title": wipTitle(details), "body": details.Body}
+	if len(labels) > 0 {
+		body["labels"] = labels
+	}
+	if len(details.Assignees) > 0 {
+		body["assignees"] = details.Assignees
+	}
+	if err := b.client.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/pulls/%d", details.Repo, pr.Number), body, nil); err != nil {
+		return err
+	}
+	return b.requestReviewers(ctx, details, pr)
+}
+
+func (b *giteaBackend) closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	return b.client.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/pulls/%d", details.Repo, pr.Number), map[string]string{"state": "closed"}, nil)
+}
+
+func (b *giteaBackend) commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error {
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", details.Repo, pr.Number)
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+func (b *giteaBackend) mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	path := fmt.Sprintf("/repos/%s/pulls/%d/merge", details.Repo, pr.Number)
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{"Do": method}, nil)
+}
+
+func (b *giteaBackend) deleteBranch(ctx context.Context, details Repo, branch string) error {
+	return b.client.do(ctx, http.MethodDelete, "/repos/"+details.Repo+"/branches/"+url.PathEscape(branch), nil, nil)
+}
+
+func (b *giteaBackend) listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error) {
+	var bodies []string
+	for page := 1; ; page++ {
+		var comments []struct {
+			Body string `json:"body"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?limit=50&page=%d", details.Repo, pr.Number, page)
+		header, err := b.client.doResponse(ctx, http.MethodGet, path, nil, &comments)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			bodies = append(bodies, comment.Body)
+		}
+		// Versions that do not paginate comments return all of them, without a link to the next page
+		if len(comments) < 50 || !strings.Contains(header.Get("Link"), `rel="next"`) {
+			return bodies, nil
+		}
+	}
+}
+
+func (b *giteaBackend) pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error) {
+	// The list cannot be filtered by branch, so the most recent match is the one with the highest number
+	var pull *giteaPull
+	for page := 1; ; page++ {
+		query := url.Values{"state": {"all"}, "limit": {"50"}, "page": {strconv.Itoa(page)}}
+		var pulls []giteaPull
+		if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/pulls?"+query.Encode(), nil, &pulls); err != nil {
+			return nil, err
+		}
+		for i := range pulls {
+			if pulls[i].Base.Ref == details.Base && pulls[i].Head.Ref == details.Head && (pull == nil || pulls[i].Number > pull.Number) {
+				pull = &pulls[i]
+			}
+		}
+		if len(pulls) < 50 {
+			break
+		}
+	}
+	if pull == nil {
+		return nil, nil
+	}
+
+	status := &pullRequestStatus{pullRequest: *pull.pullRequest(), State: prStateOpen, Mergeable: "conflicting"}
+	switch {
+	case pull.Merged:
+		status.State = prStateMerged
+	case pull.State != prStateOpen:
+		status.State = prStateClosed
+	case isWIP(pull.Title):
+		status.State = prStateDraft
+	}
+	if pull.Mergeable {
+		status.Mergeable = "mergeable"
+	}
+
+	decision, err := b.reviewDecision(ctx, details, pull.Number)
+	if err != nil {
+		return nil, err
+	}
+	status.ReviewDecision = decision
+
+	var combined struct {
+		Statuses []struct {
+			Status string `json:"status"`
+		} `json:"statuses"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/commits/"+pull.Head.SHA+"/status", nil, &combined); err != nil {
+		return nil, fmt.Errorf("failed to get commit statuses: %w", err)
+	}
+	checks := make([]checkState, 0, len(combined.Statuses))
+	for _, s := range combined.Statuses {
+		checks = append(checks, checkState{State: s.Status})
+	}
+	status.Checks = rollupChecks(checks)
+	return status, nil
+}
+
+// reviewDecision derives a review decision from the latest review of every reviewer: requested changes win over approvals,
+// and a pending review request makes a review required. The approvals required by branch protection
+// can only be read by repository admins, so they are not taken into account.
+func (b *giteaBackend) reviewDecision(ctx context.Context, details Repo, number int) (string, error) {
+	var reviews []struct {
+		State     string `json:"state"` // APPROVED, REQUEST_CHANGES, REQUEST_REVIEW, COMMENT or PENDING
+		Dismissed bool   `json:"dismissed"`
+		Stale     bool   `json:"stale"`
+		User      *struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Team *struct {
+			Name string `json:"name"`
+		} `json:"team"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d/reviews?limit=50", details.Repo, number), nil, &reviews); err != nil {
+		return "", fmt.Errorf("failed to list reviews: %w", err)
+	}
+
+	latest := map[string]string{}
+	for _, review := range reviews {
+		// Approvals of an earlier head no longer count
+		if review.Dismissed || review.State == "COMMENT" || review.State == "PENDING" || (review.State == "APPROVED" && review.Stale) {
+			continue
+		}
+		var reviewer string
+		switch {
+		case review.User != nil:
+			reviewer = review.User.Login
+		case review.Team != nil:
+			reviewer = "team:" + review.Team.Name
+		}
+		latest[reviewer] = review.State
+	}
+
+	decision := ""
+	for _, state := range latest {
+		switch state {
+		case "REQUEST_CHANGES":
+			return reviewChangesRequested, nil
+		case "REQUEST_REVIEW":
+			decision = reviewRequired
+		case "APPROVED":
+			if decision == "" {
+				decision = reviewApproved
+			}
+		}
+	}
+	return decision, nil
+}
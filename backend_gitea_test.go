@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitea is a minimal stand-in for the Gitea REST API
+type fakeGitea struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+	pulls    []giteaPull
+}
+
+func newFakeGitea(t *testing.T) (*fakeGitea, *httptest.Server) {
+	t.Helper()
+	fake := &fakeGitea{bodies: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, key)
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.bodies[key] = body
+
+	if r.Header.Get("Authorization") != "token secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "token is required"}`)
+		return
+	}
+
+	switch key {
+	case "GET /api/v1/repos/org/r/labels":
+		fmt.Fprint(w, `[{"id": 7, "name": "bug"}, {"id": 9, "name": "chore"}]`)
+	case "POST /api/v1/repos/org/r/pulls":
+		pull := giteaPull{Number: len(f.pulls) + 1, HTMLURL: fmt.Sprintf("https://gitea.test/org/r/pulls/%d", len(f.pulls)+1)}
+		pull.Base.Ref = fmt.Sprint(body["base"])
+		pull.Head.Ref = fmt.Sprint(body["head"])
+		f.pulls = append(f.pulls, pull)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pull)
+	case "GET /api/v1/repos/org/r/pulls":
+		json.NewEncoder(w).Encode(f.pulls)
+	case "GET /api/v1/repos/org/r/pulls/1":
+		fmt.Fprint(w, `{"number": 1, "labels": [{"id": 7, "name": "bug"}], "assignees": [{"login": "carol"}, {"login": "alice"}]}`)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+func TestGiteaBackendCreate(t *testing.T) {
+	fake, server := newFakeGitea(t)
+	be := newGiteaBackend(server.URL+"/api/v1", "secret")
+	ctx := context.Background()
+
+	details := Repo{Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B", Draft: true,
+		Labels: []string{"chore"}, Assignees: []string{"alice"}, Reviewers: []string{"bob", "org/team"}}
+	pr, err := be.createPullRequest(ctx, details)
+	if err != nil {
+		t.Fatalf("createPullRequest failed: %v", err)
+	}
+	if pr.Number != 1 || pr.URL != "https://gitea.test/org/r/pulls/1" {
+		t.Errorf("Unexpected PR: %+v", pr)
+	}
+
+	body := fake.bodies["POST /api/v1/repos/org/r/pulls"]
+	if body["title"] != "WIP: T" || body["head"] != "dev" || body["base"] != "main" {
+		t.Errorf("Unexpected create body: %v", body)
+	}
+	if fmt.Sprint(body["labels"]) != "[9]" || fmt.Sprint(body["assignees"]) != "[alice]" {
+		t.Errorf("Unexpected metadata in create body: %v", body)
+	}
+	reviewers := fake.bodies["POST /api/v1/repos/org/r/pulls/1/requested_reviewers"]
+	if fmt.Sprint(reviewers["reviewers"]) != "[bob]" || fmt.Sprint(reviewers["team_reviewers"]) != "[team]" {
+		t.Errorf("Unexpected reviewers body: %v", reviewers)
+	}
+
+	found, err := be.findPullRequest(ctx, details)
+	if err != nil || found == nil || found.Number != 1 {
+		t.Errorf("Expected to find PR 1, got %v, %v", found, err)
+	}
+	if found, _ := be.findPullRequest(ctx, Repo{Repo: "org/r", Base: "release", Head: "dev"}); found != nil {
+		t.Errorf("Expected no PR for another base, got %+v", found)
+	}
+}
+
+func TestGiteaBackendUpdate(t *testing.T) {
+	fake, server := newFakeGitea(t)
+	be := newGiteaBackend(server.URL+"/api/v1", "secret")
+
+	// Labels and assignees are added to those of the PR, since the update replaces them
+	details := Repo{Repo: "org/r", Base: "main", Head: "dev", Title: "T", Labels: []string{"chore"}, Assignees: []string{"alice"}}
+	if err := be.updatePullRequest(context.Background(), details, &pullRequest{Number: 1}); err != nil {
+		t.Fatalf("updatePullRequest failed: %v", err)
+	}
+	body := fake.bodies["PATCH /api/v1/repos/org/r/pulls/1"]
+	if fmt.Sprint(body["labels"]) != "[7 9]" || fmt.Sprint(body["assignees"]) != "[carol alice]" {
+		t.Errorf("Unexpected update body: %v", body)
+	}
+}
+
+func TestGiteaBackendErrors(t *testing.T) {
+	_, server := newFakeGitea(t)
+	ctx := context.Background()
+
+	be := newGiteaBackend(server.URL+"/api/v1", "secret")
+	if _, err := be.createPullRequest(ctx, Repo{Repo: "org/r", Labels: []string{"missing"}}); classOf(err) != classValidation {
+		t.Errorf("Expected validation error for unknown label, got %v", err)
+	}
+	if _, err := newGiteaBackend(server.URL+"/api/v1", "wrong").findPullRequest(ctx, Repo{Repo: "org/r"}); classOf(err) != classAuth {
+		t.Errorf("Expected auth error, got %v", err)
+	}
+}
+
+func TestGiteaBackendListComments(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		var comments []string
+		switch page {
+		case "1":
+			for i := 0; i < 50; i++ {
+				comments = append(comments, fmt.Sprintf(`{"body": "c%d"}`, i))
+			}
+			w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next"`)
+		case "2":
+			comments = []string{`{"body": "last"}`}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(comments, ","))
+	}))
+	defer server.Close()
+
+	bodies, err := newGiteaBackend(server.URL+"/api/v1", "secret").listComments(context.Background(), Repo{Repo: "org/r"}, &pullRequest{Number: 1})
+	if err != nil {
+		t.Fatalf("listComments failed: %v", err)
+	}
+	if len(bodies) != 51 || bodies[50] != "last" || fmt.Sprint(pages) != "[1 2]" {
+		t.Errorf("Expected 51 comments from two pages, got %d (pages: %v)", len(bodies), pages)
+	}
+}
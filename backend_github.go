@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// githubDefaultHost is the host used when GH_HOST is not set
+const githubDefaultHost = "github.com"
+
+// githubBackend talks to the GitHub REST API directly, without the gh CLI
+type githubBackend struct {
+	client     *apiClient
+	graphqlURL string // Used for what the REST API cannot do, such as enabling auto-merge
+}
+
+// githubAPIURL returns the REST API base URL for a GitHub or GitHub Enterprise host
+func githubAPIURL(host string) string {
+	if host == "" || host == githubDefaultHost {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// newGitHubBackend returns a backend for the API at baseURL authenticated with token
+func newGitHubBackend(baseURL, token string) *githubBackend {
+	b := &githubBackend{client: newAPIClient(baseURL, func(req *http.Request) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	})}
+
+	// GitHub Enterprise Server serves GraphQL at /api/graphql next to /api/v3
+	b.graphqlURL = b.client.baseURL + "/graphql"
+	if strings.HasSuffix(b.client.baseURL, "/api/v3") {
+		b.graphqlURL = strings.TrimSuffix(b.client.baseURL, "/v3") + "/graphql"
+	}
+	return b
+}
+
+// githubAPIBackendFor returns the shared REST backend for a GitHub host.
+// The host defaults to GH_HOST, then github.com.
+func githubAPIBackendFor(host string) (backend, error) {
+	if host == "" {
+		host = os.Getenv("GH_HOST")
+	}
+	if host == "" {
+		host = githubDefaultHost
+	}
+	return cachedBackend(providerGitHub+"|"+host, func() (backend, error) {
+		token, err := githubToken(host)
+		be := newGitHubBackend(githubAPIURL(host), token)
+		be.client.authErr = err
+		return be, nil
+	})
+}
+
+// githubToken finds a token for host in the environment, the gh configuration or `gh auth token`
+func githubToken(host string) (string, error) {
+	envVars := []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	if host != githubDefaultHost {
+		envVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+	}
+	for _, name := range envVars {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
+
+	if token := ghConfigToken(host); token != "" {
+		return token, nil
+	}
+
+	out, err := runCommandOutput(context.Background(), "gh", "auth", "token", "--hostname", host)
+	if err == nil && strings.TrimSpace(string(out)) != "" {
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", withClass(classAuth, fmt.Errorf("no GitHub token found for %s: set GH_TOKEN or run `gh auth login`", host))
+}
+
+// ghConfigDir returns the directory where gh keeps its configuration
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh")
+}
+
+// ghConfigToken reads the token for host from gh's hosts.yml, if it is stored there
+func ghConfigToken(host string) string {
+	dir := ghConfigDir()
+	if dir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return ""
+	}
+	return hosts[host].OAuthToken
+}
+
+// githubPull is the subset of the GitHub pull request resource used here
+type githubPull struct {
+	Number    int     `json:"number"`
+	NodeID    string  `json:"node_id,omitempty"`
+	HTMLURL   string  `json:"html_url"`
+	State     string  `json:"state,omitempty"`
+	Draft     bool    `json:"draft,omitempty"`
+	MergedAt  *string `json:"merged_at,omitempty"`
+	Mergeable *bool   `json:"mergeable,omitempty"`
+	Head      struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+func (p githubPull) pullRequest() *pullRequest {
+	return &pullRequest{Number: p.Number, URL: p.HTMLURL}
+}
+
+// splitReviewers separates user reviewers from `org/team-slug` team reviewers
+func splitReviewers(reviewers []string) (users, teams []string) {
+	for _, reviewer := range reviewers {
+		if _, team, ok := strings.Cut(reviewer, "/"); ok {
+			teams = append(teams, team)
+		} else {
+			users = append(users, reviewer)
+		}
+	}
+	return users, teams
+}
+
+func (b *githubBackend) describeCreate(details Repo) string {
+	return fmt.Sprintf("POST %s/repos/%s/pulls (title: %q, head: %q, base: %q, draft: %t, labels: %v, assignees: %v, reviewers: %v)",
+		b.client.baseURL, details.Repo, details.Title, details.Head, details.Base, details.Draft, details.Labels, details.Assignees, details.Reviewers)
+}
+
+func (b *githubBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	var created githubPull
+	err := b.client.do(ctx, http.MethodPost, "/repos/"+details.Repo+"/pulls", map[string]interface{}{
+		"title": details.Title,
+		"body":  details.Body,
+		"head":  details.Head,
+		"base":  details.Base,
+		"draft": details.Draft,
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := created.pullRequest()
+	if err := b.applyMetadata(ctx, details, pr); err != nil {
+		return pr, fmt.Errorf("PR %s was created but: %w", pr.URL, err)
+	}
+	return pr, nil
+}
+
+// applyMetadata adds the labels, assignees and reviewers of details to pr
+func (b *githubBackend) applyMetadata(ctx context.Context, details Repo, pr *pullRequest) error {
+	issuePath := fmt.Sprintf("/repos/%s/issues/%d", details.Repo, pr.Number)
+
+	if len(details.Labels) > 0 {
+		if err := b.client.do(ctx, http.MethodPost, issuePath+"/labels", map[string][]string{"labels": details.Labels}, nil); err != nil {
+			return fmt.Errorf("failed to add labels: %w", err)
+		}
+	}
+	if len(details.Assignees) > 0 {
+		if err := b.client.do(ctx, http.MethodPost, issuePath+"/assignees", map[string][]string{"assignees": details.Assignees}, nil); err != nil {
+			return fmt.Errorf("failed to add assignees: %w", err)
+		}
+	}
+	if len(details.Reviewers) > 0 {
+		users, teams := splitReviewers(details.Reviewers)
+		body := map[string][]string{"reviewers": users, "team_reviewers": teams}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/requested_reviewers", details.Repo, pr.Number)
+		if err := b.client.do(ctx, http.MethodPost, path, body, nil); err != nil {
+			return fmt.Errorf("failed to request reviewers: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *githubBackend) findPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	owner, _, _ := strings.Cut(details.Repo, "/")
+	head := details.Head
+	if !strings.Contains(head, ":") {
+		head = owner + ":" + head
+	}
+
+	query := url.Values{"state": {"open"}, "base": {details.Base}, "head": {head}}
+	var pulls []githubPull
+	if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/pulls?"+query.Encode(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return pulls[0].pullRequest(), nil
+}
+
+func (b *githubBackend) updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	path := "/repos/" + details.Repo + "/pulls/" + strconv.Itoa(pr.Number)
+	if err := b.client.do(ctx, http.MethodPatch, path, map[string]string{"title": details.Title, "body": details.Body}, nil); err != nil {
+		return err
+	}
+	return b.applyMetadata(ctx, details, pr)
+}
+
+func (b *githubBackend) closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	path := "/repos/" + details.Repo + "/pulls/" + strconv.Itoa(pr.Number)
+	return b.client.do(ctx, http.MethodPatch, path, map[string]string{"state": "closed"}, nil)
+}
+
+func (b *githubBackend) pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error) {
+	owner, _, _ := strings.Cut(details.Repo, "/")
+	head := details.Head
+	if !strings.Contains(head, ":") {
+		head = owner + ":" + head
+	}
+
+	query := url.Values{"state": {"all"}, "base": {details.Base}, "head": {head}, "per_page": {"1"}}
+	var pulls []githubPull
+	if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/pulls?"+query.Encode(), nil, &pulls); err != nil {
+		return nil, err
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+
+	// Mergeability is only computed for single pull requests, not in lists
+	var pull githubPull
+	pullPath := fmt.Sprintf("/repos/%s/pulls/%d", details.Repo, pulls[0].Number)
+	if err := b.client.do(ctx, http.MethodGet, pullPath, nil, &pull); err != nil {
+		return nil, err
+	}
+
+	status := &pullRequestStatus{pullRequest: *pull.pullRequest(), State: pull.State, Mergeable: "unknown"}
+	switch {
+	case pull.MergedAt != nil:
+		status.State = prStateMerged
+	case pull.State == prStateOpen && pull.Draft:
+		status.State = prStateDraft
+	}
+	if pull.Mergeable != nil {
+		status.Mergeable = "conflicting"
+		if *pull.Mergeable {
+			status.Mergeable = "mergeable"
+		}
+	}
+
+	// The review decision takes branch protection into account, so like gh it is only empty when no review is required.
+	// The REST API has no equivalent: a PR without reviews would look like one that needs none.
+	var review struct {
+		Node struct {
+			ReviewDecision string `json:"reviewDecision"`
+		} `json:"node"`
+	}
+	reviewQuery := `query($id: ID!) { node(id: $id) { ... on PullRequest { reviewDecision } } }`
+	if err := b.graphql(ctx, reviewQuery, map[string]interface{}{"id": pull.NodeID}, &review); err != nil {
+		return nil, fmt.Errorf("failed to look up the review decision: %w", err)
+	}
+	status.ReviewDecision = strings.ToLower(review.Node.ReviewDecision)
+
+	checks, err := b.checks(ctx, details, pull.Head.SHA)
+	if err != nil {
+		return nil, err
+	}
+	status.Checks = rollupChecks(checks)
+	return status, nil
+}
+
+// checks returns the check runs and commit statuses of a commit
+func (b *githubBackend) checks(ctx context.Context, details Repo, sha string) ([]checkState, error) {
+	var runs struct {
+		CheckRuns []checkState `json:"check_runs"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/commits/"+sha+"/check-runs?per_page=100", nil, &runs); err != nil {
+		return nil, fmt.Errorf("failed to list check runs: %w", err)
+	}
+	var combined struct {
+		Statuses []checkState `json:"statuses"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, "/repos/"+details.Repo+"/commits/"+sha+"/status", nil, &combined); err != nil {
+		return nil, fmt.Errorf("failed to get commit statuses: %w", err)
+	}
+	return append(runs.CheckRuns, combined.Statuses...), nil
+}
+
+func (b *githubBackend) mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	path := "/repos/" + details.Repo + "/pulls/" + strconv.Itoa(pr.Number) + "/merge"
+	return b.client.do(ctx, http.MethodPut, path, map[string]string{"merge_method": method}, nil)
+}
+
+func (b *githubBackend) deleteBranch(ctx context.Context, details Repo, branch string) error {
+	return b.client.do(ctx, http.MethodDelete, "/repos/"+details.Repo+"/git/refs/heads/"+branch, nil, nil)
+}
+
+func (b *githubBackend) commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error {
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", details.Repo, pr.Number)
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+func (b *githubBackend) listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error) {
+	var bodies []string
+	for page := 1; ; page++ {
+		var comments []struct {
+			Body string `json:"body"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", details.Repo, pr.Number, page)
+		if err := b.client.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			bodies = append(bodies, comment.Body)
+		}
+		if len(comments) < 100 {
+			return bodies, nil
+		}
+	}
+}
+
+// graphql runs a GraphQL query and decodes its data into out
+func (b *githubBackend) graphql(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	body := map[string]interface{}{"query": query, "variables": variables}
+	if err := b.client.do(ctx, http.MethodPost, b.graphqlURL, body, &resp); err != nil {
+		return err
+	}
+
+	if len(resp.Errors) > 0 {
+		messages := make([]string, 0, len(resp.Errors))
+		class := classValidation
+		for _, e := range resp.Errors {
+			messages = append(messages, e.Message)
+			switch e.Type {
+			case "RATE_LIMITED":
+				class = classRateLimited
+			case "NOT_FOUND":
+				class = classNotFound
+			case "FORBIDDEN":
+				class = classAuth
+			}
+		}
+		return withClass(class, fmt.Errorf("GraphQL: %s", strings.Join(messages, ": ")))
+	}
+	if out != nil && len(resp.Data) > 0 {
+		return json.Unmarshal(resp.Data, out)
+	}
+	return nil
+}
+
+func (b *githubBackend) enableAutoMerge(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	var pull githubPull
+	if err := b.client.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", details.Repo, pr.Number), nil, &pull); err != nil {
+		return err
+	}
+
+	const mutation = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) { clientMutationId }
+}`
+	return b.graphql(ctx, mutation, map[string]interface{}{"id": pull.NodeID, "method": strings.ToUpper(method)}, nil)
+}
+
+// userReposPath returns the path listing the repositories of a user. Only the listing of the authenticated user
+// includes private repositories, so it is used when owner is that user.
+func (b *githubBackend) userReposPath(ctx context.Context, owner string) string {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, "/user", nil, &user); err == nil && strings.EqualFold(user.Login, owner) {
+		return "/user/repos?affiliation=owner&per_page=100"
+	}
+	return "/users/" + url.PathEscape(owner) + "/repos?type=owner&per_page=100"
+}
+
+func (b *githubBackend) listRepositories(ctx context.Context, owner string) ([]repository, error) {
+	var repos []repository
+	base := "/orgs/" + url.PathEscape(owner) + "/repos?type=all&per_page=100"
+	for page := 1; ; page++ {
+		var listed []struct {
+			FullName      string   `json:"full_name"`
+			Topics        []string `json:"topics"`
+			Language      string   `json:"language"`
+			Visibility    string   `json:"visibility"`
+			Archived      bool     `json:"archived"`
+			Fork          bool     `json:"fork"`
+			DefaultBranch string   `json:"default_branch"`
+		}
+		err := b.client.do(ctx, http.MethodGet, base+"&page="+strconv.Itoa(page), nil, &listed)
+		if page == 1 && classOf(err) == classNotFound {
+			// Not an organization, so list the repositories of the user
+			base = b.userReposPath(ctx, owner)
+			err = b.client.do(ctx, http.MethodGet, base+"&page=1", nil, &listed)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range listed {
+			repos = append(repos, repository{FullName: l.FullName, Topics: l.Topics, Language: l.Language,
+				Visibility: l.Visibility, Archived: l.Archived, Fork: l.Fork, DefaultBranch: l.DefaultBranch})
+		}
+		if len(listed) < 100 {
+			return repos, nil
+		}
+	}
+}
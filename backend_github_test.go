@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitHub is a minimal stand-in for the GitHub REST API
+type fakeGitHub struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+	pulls    []githubPull
+	open     map[string]bool // "base|head" pairs with an open PR
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *httptest.Server) {
+	t.Helper()
+	fake := &fakeGitHub{bodies: map[string]map[string]interface{}{}, open: map[string]bool{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, key)
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.bodies[key] = body
+
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "Bad credentials"}`)
+		return
+	}
+
+	switch {
+	case key == "POST /repos/org/missing/pulls":
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+	case key == "POST /repos/org/r/pulls":
+		if f.open[fmt.Sprint(body["base"], "|", body["head"])] {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Validation Failed", "errors": [{"message": "A pull request already exists for org:dev."}]}`)
+			return
+		}
+		pull := githubPull{Number: len(f.pulls) + 1, HTMLURL: "https://github.com/org/r/pull/" + strconv.Itoa(len(f.pulls)+1)}
+		f.pulls = append(f.pulls, pull)
+		f.open[fmt.Sprint(body["base"], "|", body["head"])] = true
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pull)
+	case key == "GET /repos/org/r/pulls":
+		q := r.URL.Query()
+		if q.Get("state") == "open" && q.Get("head") == "org:dev" && f.open[q.Get("base")+"|dev"] {
+			json.NewEncoder(w).Encode([]githubPull{f.pulls[0]})
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+func TestGitHubBackendCreate(t *testing.T) {
+	fake, server := newFakeGitHub(t)
+	be := newGitHubBackend(server.URL, "secret")
+
+	details := Repo{Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B", Draft: true,
+		Labels: []string{"l1"}, Assignees: []string{"a1"}, Reviewers: []string{"u1", "org/team-1"}}
+	pr, err := be.createPullRequest(context.Background(), details)
+	if err != nil {
+		t.Fatalf("createPullRequest failed: %v", err)
+	}
+	if pr.Number != 1 || pr.URL != "https://github.com/org/r/pull/1" {
+		t.Errorf("Unexpected PR: %+v", pr)
+	}
+
+	want := []string{
+		"POST /repos/org/r/pulls",
+		"POST /repos/org/r/issues/1/labels",
+		"POST /repos/org/r/issues/1/assignees",
+		"POST /repos/org/r/pulls/1/requested_reviewers",
+	}
+	if !equalSlices(fake.requests, want) {
+		t.Errorf("Unexpected requests: %v", fake.requests)
+	}
+	if fake.bodies["POST /repos/org/r/pulls"]["draft"] != true {
+		t.Errorf("Expected draft in create body, got %v", fake.bodies["POST /repos/org/r/pulls"])
+	}
+	reviewers := fake.bodies["POST /repos/org/r/pulls/1/requested_reviewers"]
+	if fmt.Sprint(reviewers["reviewers"]) != "[u1]" || fmt.Sprint(reviewers["team_reviewers"]) != "[team-1]" {
+		t.Errorf("Unexpected reviewers body: %v", reviewers)
+	}
+}
+
+func TestGitHubBackendFindAndErrors(t *testing.T) {
+	_, server := newFakeGitHub(t)
+	be := newGitHubBackend(server.URL, "secret")
+	ctx := context.Background()
+	details := Repo{Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B"}
+
+	if pr, err := be.findPullRequest(ctx, details); err != nil || pr != nil {
+		t.Fatalf("Expected no open PR, got %v, %v", pr, err)
+	}
+	if _, err := be.createPullRequest(ctx, details); err != nil {
+		t.Fatalf("createPullRequest failed: %v", err)
+	}
+	if pr, err := be.findPullRequest(ctx, details); err != nil || pr == nil || pr.Number != 1 {
+		t.Fatalf("Expected to find PR 1, got %v, %v", pr, err)
+	}
+
+	if _, err := be.createPullRequest(ctx, details); classOf(err) != classAlreadyExists {
+		t.Errorf("Expected already_exists, got %v (%s)", err, classOf(err))
+	}
+	if _, err := be.createPullRequest(ctx, Repo{Repo: "org/missing", Base: "main", Head: "dev"}); classOf(err) != classNotFound {
+		t.Errorf("Expected not_found, got %v", err)
+	}
+	if _, err := newGitHubBackend(server.URL, "wrong").createPullRequest(ctx, details); classOf(err) != classAuth {
+		t.Errorf("Expected auth, got %v", err)
+	}
+}
+
+func TestAPIClientRateLimit(t *testing.T) {
+	slept := mockSleep(t)
+	reset := time.Now().Add(time.Hour).Unix()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		if calls == 1 {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "You have exceeded a secondary rate limit"}`)
+	}))
+	defer server.Close()
+
+	client := newAPIClient(server.URL, nil)
+	if err := client.do(context.Background(), http.MethodGet, "/", nil, nil); err != nil {
+		t.Fatalf("First request failed: %v", err)
+	}
+
+	err := client.do(context.Background(), http.MethodGet, "/", nil, nil)
+	if len(*slept) != 1 || (*slept)[0] < 59*time.Minute {
+		t.Errorf("Expected to wait for the rate limit reset, slept %v", *slept)
+	}
+	if classOf(err) != classRateLimited {
+		t.Errorf("Expected rate_limited, got %v", err)
+	}
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute {
+		t.Errorf("Expected Retry-After of 1m, got %+v", apiErr)
+	}
+}
+
+func TestCreatePullRequestWithGitHubBackend(t *testing.T) {
+	fake, server := newFakeGitHub(t)
+	originalMockBackend := mockBackend
+	defer func() { mockBackend = originalMockBackend }()
+	mockBackend = newGitHubBackend(server.URL, "secret")
+
+	config := &Config{Preflight: new(bool), Output: outputJSON, Repos: map[string]Repo{"r": {Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B"}}}
+	output := captureStdout(t, func() {
+		if err := createPullRequest(config, false); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+
+	var report struct{ Results []result }
+	json.Unmarshal([]byte(output), &report)
+	if len(report.Results) != 1 || report.Results[0].URL != "https://github.com/org/r/pull/1" {
+		t.Errorf("Unexpected report: %s", output)
+	}
+	if fake.requests[0] != "GET /repos/org/r/pulls" {
+		t.Errorf("Expected existing PR lookup first, got %v", fake.requests)
+	}
+}
+
+func TestGitHubToken(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte("github.com:\n    oauth_token: from-config\n    user: me\n"), 0o600)
+	t.Setenv("GH_CONFIG_DIR", dir)
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+
+	if token, err := githubToken("github.com"); err != nil || token != "from-config" {
+		t.Errorf("Expected token from gh config, got %q, %v", token, err)
+	}
+
+	t.Setenv("GH_TOKEN", "from-env")
+	if token, _ := githubToken("github.com"); token != "from-env" {
+		t.Errorf("Expected GH_TOKEN to take precedence, got %q", token)
+	}
+
+	t.Setenv("GH_ENTERPRISE_TOKEN", "enterprise")
+	if token, _ := githubToken("github.example.com"); token != "enterprise" {
+		t.Errorf("Expected GH_ENTERPRISE_TOKEN for enterprise hosts, got %q", token)
+	}
+}
+
+func TestGitHubAPIURL(t *testing.T) {
+	if got := githubAPIURL(""); got != "https://api.github.com" {
+		t.Errorf("Unexpected URL for github.com: %s", got)
+	}
+	if got := githubAPIURL("github.example.com"); got != "https://github.example.com/api/v3" {
+		t.Errorf("Unexpected URL for enterprise: %s", got)
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gitlabDefaultHost is the host used for GitLab entries without `host`
+const gitlabDefaultHost = "gitlab.com"
+
+// gitlabBackend opens merge requests through the GitLab REST API
+type gitlabBackend struct {
+	client *apiClient
+
+	mu      sync.Mutex
+	userIDs map[string]int // Cache of username to user ID lookups
+}
+
+// newGitLabBackend returns a backend for the API at baseURL (ending in /api/v4) authenticated with token
+func newGitLabBackend(baseURL, token string) *gitlabBackend {
+	return &gitlabBackend{
+		client: newAPIClient(baseURL, func(req *http.Request) {
+			if token != "" {
+				req.Header.Set("PRIVATE-TOKEN", token)
+			}
+		}),
+		userIDs: map[string]int{},
+	}
+}
+
+// gitlabToken returns the token for GitLab from the environment
+func gitlabToken(host string) (string, error) {
+	// Like glab, the environment token is for the host named by GITLAB_HOST, gitlab.com by default
+	envHost := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(os.Getenv("GITLAB_HOST"), "https://"), "http://"), "/")
+	if envHost == "" {
+		envHost = gitlabDefaultHost
+	}
+	if strings.EqualFold(host, envHost) {
+		for _, name := range []string{"GITLAB_TOKEN", "GL_TOKEN"} {
+			if token := os.Getenv(name); token != "" {
+				return token, nil
+			}
+		}
+	}
+	if token := glabConfigToken(host); token != "" {
+		return token, nil
+	}
+	return "", withClass(classAuth, fmt.Errorf("no GitLab token found for %s: set GITLAB_TOKEN (and GITLAB_HOST for a self-hosted instance) or run `glab auth login`", host))
+}
+
+// glabConfigToken reads the token for host from glab's config.yml, if it is stored there
+func glabConfigToken(host string) string {
+	dir := os.Getenv("GLAB_CONFIG_DIR")
+	if dir == "" {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			dir = filepath.Join(xdg, "glab-cli")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "glab-cli")
+		} else {
+			return ""
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.yml"))
+	if err != nil {
+		return ""
+	}
+
+	var config struct {
+		Hosts map[string]struct {
+			Token string `yaml:"token"`
+		} `yaml:"hosts"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return ""
+	}
+	return config.Hosts[host].Token
+}
+
+// gitlabBackendFor returns the shared backend for a GitLab host
+func gitlabBackendFor(host string) (backend, error) {
+	if host == "" {
+		host = gitlabDefaultHost
+	}
+	return cachedBackend(providerGitLab+"|"+host, func() (backend, error) {
+		token, err := gitlabToken(host)
+		be := newGitLabBackend("https://"+host+"/api/v4", token)
+		be.client.authErr = err
+		return be, nil
+	})
+}
+
+// projectPath returns the API path of the project for details
+func (b *gitlabBackend) projectPath(details Repo) string {
+	return "/projects/" + url.PathEscape(details.Repo)
+}
+
+// gitlabMergeRequest is the subset of the GitLab merge request resource used here
+type gitlabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+func (mr gitlabMergeRequest) pullRequest() *pullRequest {
+	return &pullRequest{Number: mr.IID, URL: mr.WebURL}
+}
+
+// gitlabMergeRequestDetails is the subset of a single GitLab merge request used for its status
+type gitlabMergeRequestDetails struct {
+	gitlabMergeRequest
+	State               string `json:"state"` // opened, closed, locked or merged
+	Draft               bool   `json:"draft"`
+	HasConflicts        bool   `json:"has_conflicts"`
+	DetailedMergeStatus string `json:"detailed_merge_status"`
+	HeadPipeline        *struct {
+		Status string `json:"status"`
+	} `json:"head_pipeline"`
+}
+
+// userID resolves a GitLab username to its numeric ID
+func (b *gitlabBackend) userID(ctx context.Context, username string) (int, error) {
+	b.mu.Lock()
+	id, ok := b.userIDs[username]
+	b.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var users []struct {
+		ID int `json:"id"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, "/users?"+url.Values{"username": {username}}.Encode(), nil, &users); err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, withClass(classNotFound, fmt.Errorf("GitLab user %q not found", username))
+	}
+
+	b.mu.Lock()
+	b.userIDs[username] = users[0].ID
+	b.mu.Unlock()
+	return users[0].ID, nil
+}
+
+// resolveUsers resolves a list of usernames to user IDs
+func (b *gitlabBackend) resolveUsers(ctx context.Context, usernames []string) ([]int, error) {
+	ids := make([]int, 0, len(usernames))
+	for _, username := range usernames {
+		id, err := b.userID(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// unsupportedFields reports team reviewers, which GitLab merge requests do not support
+func (b *gitlabBackend) unsupportedFields(details Repo) []string {
+	if _, teams := splitReviewers(details.Reviewers); len(teams) > 0 {
+		return []string{"team reviewers"}
+	}
+	return nil
+}
+
+// gitlabDeveloperAccess is the access level from which members can push to a project
+const gitlabDeveloperAccess = 30
+
+// preflight checks an entry against the GitLab API: the project exists, is not archived and can be pushed to,
+// the branches exist and head is ahead of base, the labels exist, and assignees and reviewers are project members
+func (b *gitlabBackend) preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error) {
+	var project struct {
+		Archived    bool `json:"archived"`
+		Permissions *struct {
+			ProjectAccess *struct {
+				AccessLevel int `json:"access_level"`
+			} `json:"project_access"`
+			GroupAccess *struct {
+				AccessLevel int `json:"access_level"`
+			} `json:"group_access"`
+		} `json:"permissions"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, b.projectPath(details), nil, &project); err != nil {
+		if classOf(err) == classNotFound {
+			return []string{fmt.Sprintf("project %s does not exist or is not accessible", details.Repo)}, nil
+		}
+		return nil, err
+	}
+
+	var problems []string
+	if fields := b.unsupportedFields(details); len(fields) > 0 {
+		problems = append(problems, errUnsupportedFields(providerName(details), fields).Error())
+	}
+	if project.Archived {
+		problems = append(problems, fmt.Sprintf("project %s is archived", details.Repo))
+	}
+	if perms := project.Permissions; perms != nil {
+		access := 0
+		if perms.ProjectAccess != nil {
+			access = perms.ProjectAccess.AccessLevel
+		}
+		if perms.GroupAccess != nil && perms.GroupAccess.AccessLevel > access {
+			access = perms.GroupAccess.AccessLevel
+		}
+		if access < gitlabDeveloperAccess {
+			problems = append(problems, fmt.Sprintf("you cannot push to %s", details.Repo))
+		}
+	}
+
+	branches := []string{details.Base}
+	if checkHead {
+		branches = append(branches, details.Head)
+	}
+	before := len(problems)
+	for _, branch := range branches {
+		path := b.projectPath(details) + "/repository/branches/" + url.PathEscape(branch)
+		if err := checkExists(&problems, b.client.do(ctx, http.MethodGet, path, nil, nil), fmt.Sprintf("branch %s does not exist", branch)); err != nil {
+			return nil, err
+		}
+	}
+	if checkHead && len(problems) == before {
+		var compare struct {
+			Commits []struct{} `json:"commits"`
+		}
+		query := url.Values{"from": {details.Base}, "to": {details.Head}}
+		if err := b.client.do(ctx, http.MethodGet, b.projectPath(details)+"/repository/compare?"+query.Encode(), nil, &compare); err != nil {
+			return nil, err
+		}
+		if len(compare.Commits) == 0 {
+			problems = append(problems, fmt.Sprintf("%s has no commits ahead of %s", details.Head, details.Base))
+		}
+	}
+
+	for _, label := range details.Labels {
+		path := b.projectPath(details) + "/labels/" + url.PathEscape(label)
+		if err := checkExists(&problems, b.client.do(ctx, http.MethodGet, path, nil, nil), fmt.Sprintf("label %q does not exist", label)); err != nil {
+			return nil, err
+		}
+	}
+	users, _ := splitReviewers(details.Reviewers)
+	for _, check := range []struct {
+		usernames []string
+		problem   string
+	}{
+		{details.Assignees, "%s cannot be assigned"},
+		{users, "%s cannot review merge requests in " + details.Repo},
+	} {
+		for _, username := range check.usernames {
+			id, err := b.userID(ctx, username)
+			if err == nil {
+				// Members include those inherited from the groups of the project
+				err = b.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/members/all/%d", b.projectPath(details), id), nil, nil)
+			}
+			if err := checkExists(&problems, err, fmt.Sprintf(check.problem, username)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return problems, nil
+}
+
+// gitlabDraftPrefix matches the title prefixes GitLab takes as marking a merge request as draft
+var gitlabDraftPrefix = regexp.MustCompile(`^\s*(?i:draft:|\[draft\]|\(draft\))\s*`)
+
+// draftTitle marks the title as draft the way GitLab expects, or removes the mark,
+// since GitLab derives the draft status of a merge request from its title
+func draftTitle(details Repo) string {
+	title := gitlabDraftPrefix.ReplaceAllString(details.Title, "")
+	if details.Draft {
+		return "Draft: " + title
+	}
+	return title
+}
+
+// mergeRequestBody builds the fields shared by creating and updating a merge request
+func (b *gitlabBackend) mergeRequestBody(ctx context.Context, details Repo) (map[string]interface{}, error) {
+	if err := checkSupportedFields(b, details); err != nil {
+		return nil, err
+	}
+	assignees, err := b.resolveUsers(ctx, details.Assignees)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve assignees: %w", err)
+	}
+	reviewers, err := b.resolveUsers(ctx, details.Reviewers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reviewers: %w", err)
+	}
+
+	body := map[string]interface{}{
+		"title":       draftTitle(details),
+		"description": details.Body,
+	}
+	if len(assignees) > 0 {
+		body["assignee_ids"] = assignees
+	}
+	if len(reviewers) > 0 {
+		body["reviewer_ids"] = reviewers
+	}
+	return body, nil
+}
+
+func (b *gitlabBackend) describeCreate(details Repo) string {
+	return fmt.Sprintf("POST %s%s/merge_requests (title: %q, source_branch: %q, target_branch: %q, labels: %v, assignees: %v, reviewers: %v)",
+		b.client.baseURL, b.projectPath(details), draftTitle(details), details.Head, details.Base, details.Labels, details.Assignees, details.Reviewers)
+}
+
+func (b *gitlabBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	body, err := b.mergeRequestBody(ctx, details)
+	if err != nil {
+		return nil, err
+	}
+	body["source_branch"] = details.Head
+	body["target_branch"] = details.Base
+	if len(details.Labels) > 0 {
+		body["labels"] = strings.Join(details.Labels, ",")
+	}
+
+	var created gitlabMergeRequest
+	if err := b.client.do(ctx, http.MethodPost, b.projectPath(details)+"/merge_requests", body, &created); err != nil {
+		return nil, err
+	}
+	return created.pullRequest(), nil
+}
+
+func (b *gitlabBackend) findPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	query := url.Values{"state": {"opened"}, "source_branch": {details.Head}, "target_branch": {details.Base}}
+	var mrs []gitlabMergeRequest
+	if err := b.client.do(ctx, http.MethodGet, b.projectPath(details)+"/merge_requests?"+query.Encode(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	return mrs[0].pullRequest(), nil
+}
+
+func (b *gitlabBackend) updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	body, err := b.mergeRequestBody(ctx, details)
+	if err != nil {
+		return err
+	}
+	if len(details.Labels) > 0 {
+		body["add_labels"] = strings.Join(details.Labels, ",")
+	}
+
+	// The user IDs replace those of the merge request, so the ones it already has are kept
+	mrPath := b.projectPath(details) + "/merge_requests/" + strconv.Itoa(pr.Number)
+	if body["assignee_ids"] != nil || body["reviewer_ids"] != nil {
+		type user struct {
+			ID int `json:"id"`
+		}
+		var current struct {
+			Assignees []user `json:"assignees"`
+			Reviewers []user `json:"reviewers"`
+		}
+		if err := b.client.do(ctx, http.MethodGet, mrPath, nil, &current); err != nil {
+			return err
+		}
+		for field, users := range map[string][]user{"assignee_ids": current.Assignees, "reviewer_ids": current.Reviewers} {
+			configured, ok := body[field].([]int)
+			if !ok {
+				continue
+			}
+			ids := make([]int, 0, len(users)+len(configured))
+			seen := map[int]bool{}
+			for _, u := range users {
+				ids = append(ids, u.ID)
+				seen[u.ID] = true
+			}
+			for _, id := range configured {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+			body[field] = ids
+		}
+	}
+	return b.client.do(ctx, http.MethodPut, mrPath, body, nil)
+}
+
+func (b *gitlabBackend) closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	return b.client.do(ctx, http.MethodPut, b.projectPath(details)+"/merge_requests/"+strconv.Itoa(pr.Number), map[string]string{"state_event": "close"}, nil)
+}
+
+func (b *gitlabBackend) commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error {
+	path := b.projectPath(details) + "/merge_requests/" + strconv.Itoa(pr.Number) + "/notes"
+	return b.client.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+func (b *gitlabBackend) deleteBranch(ctx context.Context, details Repo, branch string) error {
+	return b.client.do(ctx, http.MethodDelete, b.projectPath(details)+"/repository/branches/"+url.PathEscape(branch), nil, nil)
+}
+
+func (b *gitlabBackend) listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error) {
+	var bodies []string
+	for page := 1; ; page++ {
+		var notes []struct {
+			Body string `json:"body"`
+		}
+		path := fmt.Sprintf("%s/merge_requests/%d/notes?per_page=100&page=%d", b.projectPath(details), pr.Number, page)
+		if err := b.client.do(ctx, http.MethodGet, path, nil, &notes); err != nil {
+			return nil, err
+		}
+		for _, note := range notes {
+			bodies = append(bodies, note.Body)
+		}
+		if len(notes) < 100 {
+			return bodies, nil
+		}
+	}
+}
+
+func (b *gitlabBackend) pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error) {
+	query := url.Values{"state": {"all"}, "source_branch": {details.Head}, "target_branch": {details.Base}, "order_by": {"created_at"}, "sort": {"desc"}, "per_page": {"1"}}
+	var mrs []gitlabMergeRequest
+	if err := b.client.do(ctx, http.MethodGet, b.projectPath(details)+"/merge_requests?"+query.Encode(), nil, &mrs); err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+
+	// The pipeline and merge status are only included for single merge requests, not in lists
+	var mr gitlabMergeRequestDetails
+	mrPath := b.projectPath(details) + "/merge_requests/" + strconv.Itoa(mrs[0].IID)
+	if err := b.client.do(ctx, http.MethodGet, mrPath, nil, &mr); err != nil {
+		return nil, err
+	}
+
+	status := &pullRequestStatus{pullRequest: *mr.pullRequest(), State: prStateOpen, Checks: checksNone, Mergeable: "unknown"}
+	switch {
+	case mr.State == "merged":
+		status.State = prStateMerged
+	case mr.State != "opened":
+		status.State = prStateClosed
+	case mr.Draft:
+		status.State = prStateDraft
+	}
+	switch {
+	case mr.HasConflicts || mr.DetailedMergeStatus == "conflict":
+		status.Mergeable = "conflicting"
+	case mr.DetailedMergeStatus != "" && mr.DetailedMergeStatus != "unchecked" && mr.DetailedMergeStatus != "checking":
+		status.Mergeable = "mergeable"
+	}
+	if mr.HeadPipeline != nil {
+		status.Checks = gitlabPipelineChecks(mr.HeadPipeline.Status)
+	}
+
+	// Approval rules are not part of the merge request, only their outcome is
+	var approvals struct {
+		ApprovalsLeft int        `json:"approvals_left"`
+		ApprovedBy    []struct{} `json:"approved_by"`
+	}
+	if err := b.client.do(ctx, http.MethodGet, mrPath+"/approvals", nil, &approvals); err != nil {
+		return nil, fmt.Errorf("failed to get approvals: %w", err)
+	}
+	switch {
+	case mr.DetailedMergeStatus == "requested_changes":
+		status.ReviewDecision = reviewChangesRequested
+	case approvals.ApprovalsLeft > 0:
+		status.ReviewDecision = reviewRequired
+	case len(approvals.ApprovedBy) > 0:
+		status.ReviewDecision = reviewApproved
+	}
+	return status, nil
+}
+
+// gitlabPipelineChecks maps the status of a GitLab pipeline to a checks rollup
+func gitlabPipelineChecks(pipelineStatus string) string {
+	switch pipelineStatus {
+	case "success", "skipped":
+		return checksSuccess
+	case "failed", "canceled":
+		return checksFailure
+	}
+	return checksPending
+}
+
+// mergePullRequest merges with the merge method configured in the project, squashing on request.
+// Rebasing is a project setting on GitLab, not a per merge request choice.
+func (b *gitlabBackend) mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	body := map[string]interface{}{}
+	switch method {
+	case mergeMethodSquash:
+		body["squash"] = true
+	case mergeMethodRebase:
+		return withClass(classValidation, fmt.Errorf("merge method %q is not supported by GitLab, use %q with a project set up for fast-forward merges", method, mergeMethodMerge))
+	}
+	return b.client.do(ctx, http.MethodPut, b.projectPath(details)+"/merge_requests/"+strconv.Itoa(pr.Number)+"/merge", body, nil)
+}
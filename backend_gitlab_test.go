@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitLab is a minimal stand-in for the GitLab REST API
+type fakeGitLab struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+	mrs      []gitlabMergeRequest
+}
+
+func newFakeGitLab(t *testing.T) (*fakeGitLab, *httptest.Server) {
+	t.Helper()
+	fake := &fakeGitLab{bodies: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.Method + " " + r.URL.EscapedPath()
+	f.requests = append(f.requests, key)
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.bodies[key] = body
+
+	if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "401 Unauthorized"}`)
+		return
+	}
+
+	switch key {
+	case "GET /api/v4/users":
+		switch r.URL.Query().Get("username") {
+		case "alice":
+			fmt.Fprint(w, `[{"id": 11}]`)
+		case "bob":
+			fmt.Fprint(w, `[{"id": 12}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	case "POST /api/v4/projects/group%2Fsub%2Fsvc/merge_requests":
+		mr := gitlabMergeRequest{IID: len(f.mrs) + 1, WebURL: fmt.Sprintf("https://gitlab.test/group/sub/svc/-/merge_requests/%d", len(f.mrs)+1)}
+		f.mrs = append(f.mrs, mr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(mr)
+	case "GET /api/v4/projects/group%2Fsub%2Fsvc/merge_requests":
+		json.NewEncoder(w).Encode(f.mrs)
+	case "GET /api/v4/projects/group%2Fsub%2Fsvc/merge_requests/1":
+		fmt.Fprint(w, `{"iid": 1, "assignees": [{"id": 13}, {"id": 11}], "reviewers": []}`)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+func TestGitLabBackendCreate(t *testing.T) {
+	fake, server := newFakeGitLab(t)
+	be := newGitLabBackend(server.URL+"/api/v4", "secret")
+
+	details := Repo{Repo: "group/sub/svc", Base: "main", Head: "dev", Title: "T", Body: "B", Draft: true,
+		Labels: []string{"l1", "l2"}, Assignees: []string{"alice"}, Reviewers: []string{"bob"}}
+	pr, err := be.createPullRequest(context.Background(), details)
+	if err != nil {
+		t.Fatalf("createPullRequest failed: %v", err)
+	}
+	if pr.Number != 1 || !strings.HasSuffix(pr.URL, "/merge_requests/1") {
+		t.Errorf("Unexpected MR: %+v", pr)
+	}
+
+	body := fake.bodies["POST /api/v4/projects/group%2Fsub%2Fsvc/merge_requests"]
+	if body["title"] != "Draft: T" || body["description"] != "B" || body["source_branch"] != "dev" || body["target_branch"] != "main" {
+		t.Errorf("Unexpected create body: %v", body)
+	}
+	if body["labels"] != "l1,l2" || fmt.Sprint(body["assignee_ids"]) != "[11]" || fmt.Sprint(body["reviewer_ids"]) != "[12]" {
+		t.Errorf("Unexpected metadata in create body: %v", body)
+	}
+
+	found, err := be.findPullRequest(context.Background(), details)
+	if err != nil || found == nil || found.Number != 1 {
+		t.Errorf("Expected to find MR 1, got %v, %v", found, err)
+	}
+}
+
+func TestGitLabBackendUpdate(t *testing.T) {
+	fake, server := newFakeGitLab(t)
+	be := newGitLabBackend(server.URL+"/api/v4", "secret")
+
+	// Assignees and reviewers are added to those of the merge request, since the update replaces them
+	details := Repo{Repo: "group/sub/svc", Base: "main", Head: "dev", Title: "T", Labels: []string{"l1"}, Assignees: []string{"alice"}, Reviewers: []string{"bob"}}
+	if err := be.updatePullRequest(context.Background(), details, &pullRequest{Number: 1}); err != nil {
+		t.Fatalf("updatePullRequest failed: %v", err)
+	}
+	body := fake.bodies["PUT /api/v4/projects/group%2Fsub%2Fsvc/merge_requests/1"]
+	if fmt.Sprint(body["assignee_ids"]) != "[13 11]" || fmt.Sprint(body["reviewer_ids"]) != "[12]" || body["add_labels"] != "l1" {
+		t.Errorf("Unexpected update body: %v", body)
+	}
+}
+
+func TestGitLabBackendErrors(t *testing.T) {
+	_, server := newFakeGitLab(t)
+	ctx := context.Background()
+
+	be := newGitLabBackend(server.URL+"/api/v4", "secret")
+	if _, err := be.createPullRequest(ctx, Repo{Repo: "group/sub/svc", Assignees: []string{"nobody"}}); classOf(err) != classNotFound {
+		t.Errorf("Expected not_found for unknown user, got %v", err)
+	}
+	if _, err := be.createPullRequest(ctx, Repo{Repo: "group/sub/svc", Reviewers: []string{"org/team"}}); classOf(err) != classValidation {
+		t.Errorf("Expected validation error for team reviewer, got %v", err)
+	}
+	if _, err := newGitLabBackend(server.URL+"/api/v4", "wrong").findPullRequest(ctx, Repo{Repo: "group/sub/svc"}); classOf(err) != classAuth {
+		t.Errorf("Expected auth error, got %v", err)
+	}
+}
+
+func TestBackendForProvider(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("GL_TOKEN", "")
+	t.Setenv("GLAB_CONFIG_DIR", t.TempDir())
+
+	be, err := backendFor(&Config{}, Repo{Provider: providerGitLab, Host: "gitlab.example.com", Repo: "g/p", Base: "main", Head: "dev", Title: "T"})
+	if err != nil {
+		t.Fatalf("backendFor failed: %v", err)
+	}
+	desc := be.describeCreate(Repo{Repo: "g/p", Base: "main", Head: "dev", Title: "T"})
+	if !strings.HasPrefix(desc, "POST https://gitlab.example.com/api/v4/projects/g%2Fp/merge_requests") {
+		t.Errorf("Unexpected dry run description: %s", desc)
+	}
+	if _, err := be.findPullRequest(context.Background(), Repo{Repo: "g/p"}); classOf(err) != classAuth {
+		t.Errorf("Expected missing token to be reported as auth error, got %v", err)
+	}
+
+	if _, err := backendFor(&Config{}, Repo{Provider: "svn"}); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+	if be, _ := backendFor(&Config{}, Repo{}); be != (ghBackend{}) {
+		t.Errorf("Expected gh backend by default, got %T", be)
+	}
+}
+
+func TestGitLabToken(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GLAB_CONFIG_DIR", dir)
+	t.Setenv("GITLAB_HOST", "")
+	t.Setenv("GITLAB_TOKEN", "public")
+	t.Setenv("GL_TOKEN", "")
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), []byte("hosts:\n  gitlab.example.com:\n    token: internal\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		envHost, host, want string
+	}{
+		{"", gitlabDefaultHost, "public"},
+		{"", "gitlab.example.com", "internal"},
+		{"https://gitlab.example.com/", "gitlab.example.com", "public"},
+		{"gitlab.example.com", gitlabDefaultHost, ""},
+		{"", "other.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("GITLAB_HOST", tt.envHost)
+		token, err := gitlabToken(tt.host)
+		if token != tt.want || (tt.want == "") != (classOf(err) == classAuth) {
+			t.Errorf("gitlabToken(%q) with GITLAB_HOST=%q = %q, %v; expected %q", tt.host, tt.envHost, token, err, tt.want)
+		}
+	}
+}
+
+func TestDraftTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		draft bool
+		want  string
+	}{
+		{"Bump Go", true, "Draft: Bump Go"},
+		{"Draft: Bump Go", true, "Draft: Bump Go"},
+		{"Bump Go", false, "Bump Go"},
+		{"Draft: Bump Go", false, "Bump Go"},
+		{"[Draft] Bump Go", false, "Bump Go"},
+		{"(draft) Bump Go", true, "Draft: Bump Go"},
+	}
+	for _, tt := range tests {
+		if got := draftTitle(Repo{Title: tt.title, Draft: tt.draft}); got != tt.want {
+			t.Errorf("draftTitle(%q, %v) = %q, expected %q", tt.title, tt.draft, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryPR is a pull request stored by memoryBackend
+type memoryPR struct {
+	pullRequest
+	Repo   string
+	Base   string
+	Head   string
+	Title  string
+	Body   string
+	Labels []string
+	Closed bool
+	Merged bool
+	// Reported by pullRequestStatus
+	Review    string
+	Checks    string
+	Mergeable string
+}
+
+// memoryBackend is an in-memory forge for tests
+type memoryBackend struct {
+	mu        sync.Mutex
+	prs       []*memoryPR
+	errors    map[string]error // Errors returned for operations on a repo
+	calls     []string
+	deleted   []string // Deleted branches as "repo:branch"
+	mergedBy  map[int]string
+	comments  map[int][]string
+	onStatus  func(pr *memoryPR) // Called before a status is reported, e.g. to let checks complete
+	autoMerge map[int]string
+	repos     []repository        // Repositories returned by listRepositories
+	problems  map[string][]string // Preflight problems by repo
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{errors: map[string]error{}, mergedBy: map[int]string{}, comments: map[int][]string{}, autoMerge: map[int]string{}}
+}
+
+// useMemoryBackend installs a memoryBackend for the duration of the test
+func useMemoryBackend(t *testing.T) *memoryBackend {
+	t.Helper()
+	originalMockBackend := mockBackend
+	t.Cleanup(func() { mockBackend = originalMockBackend })
+
+	be := newMemoryBackend()
+	mockBackend = be
+	return be
+}
+
+func (m *memoryBackend) record(op string, details Repo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, op+" "+details.Repo)
+	if err, ok := m.errors[op+" "+details.Repo]; ok {
+		return err
+	}
+	return m.errors[details.Repo]
+}
+
+// add stores an open pull request and returns it
+func (m *memoryBackend) add(details Repo) *memoryPR {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	number := len(m.prs) + 1
+	pr := &memoryPR{
+		pullRequest: pullRequest{Number: number, URL: fmt.Sprintf("https://forge.test/%s/pull/%d", details.Repo, number)},
+		Repo:        details.Repo,
+		Base:        details.Base,
+		Head:        details.Head,
+		Title:       details.Title,
+		Body:        details.Body,
+		Labels:      details.Labels,
+	}
+	m.prs = append(m.prs, pr)
+	return pr
+}
+
+func (m *memoryBackend) find(details Repo) *memoryPR {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pr := range m.prs {
+		if pr.Repo == details.Repo && pr.Base == details.Base && pr.Head == details.Head && !pr.Closed {
+			return pr
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) describeCreate(details Repo) string {
+	return "memory create " + details.Repo
+}
+
+func (m *memoryBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	if err := m.record("create", details); err != nil {
+		return nil, err
+	}
+	pr := m.add(details)
+	return &pr.pullRequest, nil
+}
+
+func (m *memoryBackend) findPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	if err := m.record("find", details); err != nil {
+		return nil, err
+	}
+	if pr := m.find(details); pr != nil {
+		found := pr.pullRequest
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryBackend) updatePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	if err := m.record("update", details); err != nil {
+		return err
+	}
+	stored := m.find(details)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored.Title, stored.Body, stored.Labels = details.Title, details.Body, details.Labels
+	return nil
+}
+
+func (m *memoryBackend) closePullRequest(ctx context.Context, details Repo, pr *pullRequest) error {
+	if err := m.record("close", details); err != nil {
+		return err
+	}
+	stored := m.find(details)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored.Closed = true
+	return nil
+}
+
+func (m *memoryBackend) pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error) {
+	if err := m.record("status", details); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.prs) - 1; i >= 0; i-- {
+		pr := m.prs[i]
+		if pr.Repo != details.Repo || pr.Base != details.Base || pr.Head != details.Head {
+			continue
+		}
+		if m.onStatus != nil {
+			m.onStatus(pr)
+		}
+		status := &pullRequestStatus{pullRequest: pr.pullRequest, State: prStateOpen, ReviewDecision: pr.Review, Checks: pr.Checks, Mergeable: pr.Mergeable}
+		switch {
+		case pr.Merged:
+			status.State = prStateMerged
+		case pr.Closed:
+			status.State = prStateClosed
+		}
+		return status, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryBackend) mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	if err := m.record("merge", details); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prs[pr.Number-1].Merged = true
+	m.prs[pr.Number-1].Closed = true
+	m.mergedBy[pr.Number] = method
+	return nil
+}
+
+func (m *memoryBackend) deleteBranch(ctx context.Context, details Repo, branch string) error {
+	if err := m.record("delete", details); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted = append(m.deleted, details.Repo+":"+branch)
+	return nil
+}
+
+func (m *memoryBackend) commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error {
+	if err := m.record("comment", details); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.comments[pr.Number] = append(m.comments[pr.Number], body)
+	return nil
+}
+
+func (m *memoryBackend) listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error) {
+	if err := m.record("comments", details); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.comments[pr.Number]...), nil
+}
+
+func (m *memoryBackend) enableAutoMerge(ctx context.Context, details Repo, pr *pullRequest, method string) error {
+	if err := m.record("auto-merge", details); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.autoMerge[pr.Number] = method
+	return nil
+}
+
+func TestCreatePullRequestWithMemoryBackend(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/existing", Base: "main", Head: "dev", Title: "Old"})
+	be.errors["org/broken"] = withClass(classAuth, fmt.Errorf("forbidden"))
+
+	config := &Config{Preflight: new(bool), OnExisting: existingUpdate, Repos: map[string]Repo{
+		"new":      {Repo: "org/new", Base: "main", Head: "dev", Title: "T", Body: "B"},
+		"existing": {Repo: "org/existing", Base: "main", Head: "dev", Title: "New", Body: "B"},
+		"broken":   {Repo: "org/broken", Base: "main", Head: "dev", Title: "T", Body: "B"},
+	}}
+
+	err := createPullRequest(config, false)
+	if err == nil || classOf(err) != classAuth {
+		t.Fatalf("Expected auth failure for org/broken, got %v", err)
+	}
+	if pr := be.find(Repo{Repo: "org/new", Base: "main", Head: "dev"}); pr == nil {
+		t.Error("Expected PR to be created for org/new")
+	}
+	if pr := be.find(Repo{Repo: "org/existing", Base: "main", Head: "dev"}); pr == nil || pr.Title != "New" {
+		t.Errorf("Expected existing PR to be updated, got %+v", pr)
+	}
+	if len(be.prs) != 2 {
+		t.Errorf("Expected 2 PRs in total, got %d", len(be.prs))
+	}
+}
+
+func TestCreatePullRequestDryRunUsesBackendDescription(t *testing.T) {
+	be := useMemoryBackend(t)
+	config := &Config{Repos: map[string]Repo{"r": {Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B"}}}
+
+	output := captureStdout(t, func() {
+		if err := createPullRequest(config, true); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "DRY RUN: Would execute: memory create org/r") {
+		t.Errorf("Expected backend description in dry run, got %q", output)
+	}
+	if len(be.calls) != 0 {
+		t.Errorf("Expected no backend calls in dry run, got %v", be.calls)
+	}
+}
+
+func TestGHBackendCreateArgs(t *testing.T) {
+	details := Repo{Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B", Labels: []string{"l"}, Draft: true}
+	got := strings.Join(ghBackend{}.createArgs(details, false), " ")
+	want := "gh pr create --draft --title T --body B --base main --head dev --label l --repo org/r"
+	if got != want {
+		t.Errorf("createArgs = %q, want %q", got, want)
+	}
+}
+
+func TestGHBackendHost(t *testing.T) {
+	originalMockRunCommandOutput := mockRunCommandOutput
+	defer func() { mockRunCommandOutput = originalMockRunCommandOutput }()
+	var calls []string
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return nil, nil
+	}
+
+	details := Repo{Repo: "org/r", Host: "ghe.example.com", Base: "main", Head: "dev", Title: "T", Body: "B"}
+	be, err := backendFor(&Config{}, details)
+	if err != nil {
+		t.Fatalf("backendFor failed: %v", err)
+	}
+	ctx := context.Background()
+	be.createPullRequest(ctx, details)
+	be.(ghBackend).preflight(ctx, details, false)
+
+	for i, want := range []string{
+		"gh pr create --title T --body B --base main --head dev --repo ghe.example.com/org/r",
+		"gh api --hostname ghe.example.com repos/org/r",
+	} {
+		if i >= len(calls) || calls[i] != want {
+			t.Errorf("Expected call %d to be %q, got %v", i, want, calls)
+		}
+	}
+}
+
+func (m *memoryBackend) listRepositories(ctx context.Context, owner string) ([]repository, error) {
+	if err := m.record("list", Repo{Repo: owner}); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var repos []repository
+	for _, r := range m.repos {
+		if strings.HasPrefix(r.FullName, owner+"/") {
+			repos = append(repos, r)
+		}
+	}
+	return repos, nil
+}
+
+func (m *memoryBackend) preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error) {
+	if err := m.record("preflight", details); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.problems[details.Repo], nil
+}
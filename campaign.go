@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prepareRepos expands matrices, applies the defaults, expands selectors, resolves body files and renders the templates of every entry.
+// It returns all entry keys in order and the entries that are valid, keyed the same way.
+// Template errors are returned together, before anything was done on a forge.
+func prepareRepos(config *Config) ([]string, map[string]Repo, error) {
+	if err := expandMatrices(config); err != nil {
+		return nil, nil, err
+	}
+	if err := applyDefaults(config); err != nil {
+		return nil, nil, err
+	}
+	if err := expandSelectors(config); err != nil {
+		return nil, nil, err
+	}
+
+	repoNames := make([]string, 0, len(config.Repos))
+	for name := range config.Repos {
+		repoNames = append(repoNames, name)
+	}
+	sort.Strings(repoNames)
+
+	// Resolve bodies and render templates for every entry before creating anything,
+	// so that a broken template in one repo does not leave the campaign half done.
+	var templateErrs []error
+	prepared := make(map[string]Repo, len(repoNames))
+	for _, repoName := range repoNames {
+		details := config.Repos[repoName]
+
+		bodyContent, err := os.ReadFile(details.Body)
+		if err == nil {
+			details.Body = string(bodyContent)
+			config.Repos[repoName] = details
+		} else {
+			if !os.IsNotExist(err) {
+				log.Printf("Warning: PR body file '%s' for repo '%s' exists but is unreadable: %v. Using raw string as body.", details.Body, repoName, err)
+			}
+		}
+
+		if missing := missingFields(details); len(missing) > 0 {
+			log.Printf("Invalid repository configuration for %s: missing %s, skipping\n", repoName, strings.Join(missing, ", "))
+			continue
+		}
+
+		rendered, err := renderRepo(repoName, details)
+		if err != nil {
+			templateErrs = append(templateErrs, err)
+			continue
+		}
+		prepared[repoName] = rendered
+	}
+
+	if len(templateErrs) > 0 {
+		return nil, nil, fmt.Errorf("failed to render templates, no pull requests were created: %w", errors.Join(templateErrs...))
+	}
+	return repoNames, prepared, nil
+}
+
+// validatePrepared checks the settings of the prepared entries that are only used when pull requests are created
+func validatePrepared(config *Config, repoNames []string, prepared map[string]Repo) error {
+	for _, repoName := range repoNames {
+		details, ok := prepared[repoName]
+		if !ok {
+			continue
+		}
+		if details.AutoMerge != "" {
+			if err := validateMergeMethod(details.AutoMerge); err != nil {
+				return fmt.Errorf("repo %s: invalid auto_merge: %w", repoName, err)
+			}
+		}
+		if err := validateEdits(details.Edits); err != nil {
+			return fmt.Errorf("repo %s: invalid edits: %w", repoName, err)
+		}
+		if config.Workspace != "" && hasChange(details) {
+			return fmt.Errorf("repo %s: script and edits cannot be used with a workspace of local clones", repoName)
+		}
+	}
+	return nil
+}
+
+// forEachRepo calls fn for every prepared entry of repoNames on a fixed pool of workers,
+// which bounds the number of concurrent forge requests. It returns the number of entries processed.
+func forEachRepo(concurrency int, repoNames []string, prepared map[string]Repo, fn func(repoName string, details Repo)) int {
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	workers := concurrency
+	if workers <= 0 {
+		workers = defaultConcurrency
+	}
+	if workers > len(prepared) {
+		workers = len(prepared)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repoName := range jobs {
+				fn(repoName, prepared[repoName])
+			}
+		}()
+	}
+
+	attempted := 0
+	for _, repoName := range repoNames {
+		if _, ok := prepared[repoName]; !ok {
+			continue
+		}
+		attempted++
+		jobs <- repoName
+	}
+	close(jobs)
+	wg.Wait()
+	return attempted
+}
+
+// textOrFile returns the content of the file named by value if there is one, and value itself otherwise,
+// like the `body` of an entry
+func textOrFile(value string) (string, error) {
+	content, err := os.ReadFile(value)
+	if err == nil {
+		return string(content), nil
+	}
+	if value == "" || os.IsNotExist(err) {
+		return value, nil
+	}
+	// Names that cannot be files, e.g. because they are too long, are plain text
+	if info, statErr := os.Stat(value); statErr != nil || info.IsDir() {
+		return value, nil
+	}
+	return "", fmt.Errorf("failed to read %s: %w", value, err)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+// statusNoChanges is the status of an entry whose edits and script did not change anything
+const statusNoChanges = "no_changes"
+
+// mockCloneURL replaces the clone URL of every repository when set, to allow tests with local repositories
+var mockCloneURL func(details Repo) string
+
+// cloneURL returns the HTTPS clone URL of a repository. Authentication is left to git's credential helpers,
+// e.g. `gh auth setup-git` for GitHub.
+func cloneURL(details Repo) (string, error) {
+	if mockCloneURL != nil {
+		return mockCloneURL(details), nil
+	}
+
+	host := details.Host
+	switch providerName(details) {
+	case providerGitHub:
+		if host == "" {
+			host = os.Getenv("GH_HOST")
+		}
+		if host == "" {
+			host = githubDefaultHost
+		}
+	case providerGitLab:
+		if host == "" {
+			host = gitlabDefaultHost
+		}
+	case providerGitea, providerForgejo:
+		if host == "" {
+			host = giteaDefaultHost
+		}
+	case providerBitbucket:
+		if host == "" {
+			return "", withClass(classValidation, fmt.Errorf("provider %q requires `host`", providerBitbucket))
+		}
+		return "https://" + host + "/scm/" + details.Repo + ".git", nil
+	default:
+		return "", withClass(classValidation, fmt.Errorf("unknown provider %q", details.Provider))
+	}
+	return "https://" + host + "/" + details.Repo + ".git", nil
+}
+
+// parseAuthor splits an author given as "Name <email>"
+func parseAuthor(author string) (name, email string, err error) {
+	addr, err := mail.ParseAddress(author)
+	if err != nil || addr.Name == "" {
+		return "", "", fmt.Errorf("invalid author %q, expected \"Name <email>\"", author)
+	}
+	return addr.Name, addr.Address, nil
+}
+
+// hasChange reports whether an entry makes its change itself, with edits or a script,
+// instead of using an existing head branch
+func hasChange(details Repo) bool {
+	return details.Script != "" || len(details.Edits) > 0
+}
+
+// describeChange describes what applyChange would do, for dry runs
+func describeChange(details Repo) string {
+	var steps []string
+	if len(details.Edits) > 0 {
+		steps = append(steps, fmt.Sprintf("apply %d edits", len(details.Edits)))
+	}
+	if details.Script != "" {
+		steps = append(steps, fmt.Sprintf("run %q", details.Script))
+	}
+	return fmt.Sprintf("clone %s at %s, %s, commit and push to %s", details.Repo, details.Base, strings.Join(steps, ", "), details.Head)
+}
+
+// cloneWorkspace shallow-clones the base branch of a repository into a temporary directory
+// and creates the head branch there. The caller removes the directory.
+func cloneWorkspace(ctx context.Context, retry retrier, details Repo) (string, error) {
+	url, err := cloneURL(details)
+	if err != nil {
+		return "", err
+	}
+	workspace, err := os.MkdirTemp("", "bulkpr-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	err = retry.do(ctx, "Cloning "+details.Repo, func(ctx context.Context) error {
+		// Start from an empty directory on every attempt, since a failed clone leaves files behind
+		if err := os.RemoveAll(workspace); err != nil {
+			return err
+		}
+		_, err := runCommandOutput(ctx, "git", "clone", "--quiet", "--depth", "1", "--branch", details.Base, url, workspace)
+		return err
+	})
+	if err != nil {
+		os.RemoveAll(workspace)
+		return "", fmt.Errorf("failed to clone %s: %w", details.Repo, err)
+	}
+	if _, err := runCommandIn(ctx, workspace, nil, "git", "checkout", "--quiet", "-b", details.Head); err != nil {
+		os.RemoveAll(workspace)
+		return "", fmt.Errorf("failed to create branch %s: %w", details.Head, err)
+	}
+	return workspace, nil
+}
+
+// previewChange applies the edits of an entry to a fresh clone and returns the resulting unified diff,
+// which is empty when nothing changes. The script is not run.
+func previewChange(ctx context.Context, retry retrier, details Repo) (string, error) {
+	workspace, err := cloneWorkspace(ctx, retry, details)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(workspace)
+
+	if err := applyEdits(workspace, details.Edits); err != nil {
+		return "", err
+	}
+	// Intent-to-add makes new files show up in the diff
+	if _, err := runCommandIn(ctx, workspace, nil, "git", "add", "--all", "--intent-to-add"); err != nil {
+		return "", err
+	}
+	diff, err := runCommandIn(ctx, workspace, nil, "git", "diff", "--no-color", "--no-ext-diff")
+	return string(diff), err
+}
+
+// applyChange makes the change of an entry: it clones the base branch into a temporary workspace,
+// creates the head branch, applies the edits, runs the script, commits everything that changed and pushes the head branch.
+// It reports false, without pushing, when the head ends up at the base commit. An existing head branch is only replaced
+// when owned, i.e. the campaign has a pull request for it or recorded it in the state file, so that a re-run regenerates
+// the change whether the pull request was opened or its creation failed; any other existing head branch fails the entry.
+func applyChange(ctx context.Context, retry retrier, key string, details Repo, owned bool) (bool, error) {
+	message := details.CommitMessage
+	if message == "" {
+		message = details.Title
+	}
+	if strings.TrimSpace(message) == "" {
+		return false, withClass(classValidation, fmt.Errorf("a commit message is required (commit_message or title)"))
+	}
+	var identity []string
+	if details.Author != "" {
+		name, email, err := parseAuthor(details.Author)
+		if err != nil {
+			return false, withClass(classValidation, err)
+		}
+		identity = []string{"-c", "user.name=" + name, "-c", "user.email=" + email}
+	}
+
+	workspace, err := cloneWorkspace(ctx, retry, details)
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(workspace)
+	git := func(args ...string) ([]byte, error) {
+		return runCommandIn(ctx, workspace, nil, append(append([]string{"git"}, identity...), args...)...)
+	}
+
+	ref := "refs/heads/" + details.Head
+	var remote string
+	err = retry.do(ctx, "Looking up "+details.Head+" on "+details.Repo, func(ctx context.Context) error {
+		out, err := runCommandIn(ctx, workspace, nil, "git", "ls-remote", "origin", ref)
+		remote, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\t")
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %s: %w", details.Head, err)
+	}
+	if remote != "" && !owned {
+		return false, withClass(classAlreadyExists, fmt.Errorf("branch %s already exists in %s and has no pull request or state of this campaign, refusing to overwrite it", details.Head, details.Repo))
+	}
+	base, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return false, err
+	}
+
+	if err := applyEdits(workspace, details.Edits); err != nil {
+		return false, err
+	}
+	if details.Script != "" {
+		env := []string{
+			"BULKPR_KEY=" + key,
+			"BULKPR_REPO=" + details.Repo,
+			"BULKPR_BASE=" + details.Base,
+			"BULKPR_HEAD=" + details.Head,
+		}
+		if _, err := runCommandIn(ctx, workspace, env, "sh", "-c", details.Script); err != nil {
+			return false, fmt.Errorf("script failed: %w", err)
+		}
+	}
+
+	status, err := git("status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if len(strings.TrimSpace(string(status))) > 0 {
+		if _, err := git("add", "--all"); err != nil {
+			return false, err
+		}
+		if _, err := git("commit", "--quiet", "--no-verify", "-m", message); err != nil {
+			return false, fmt.Errorf("failed to commit: %w", err)
+		}
+	}
+	// A script may commit its changes itself, so the change is whatever HEAD has on top of base
+	head, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return false, err
+	}
+	if string(head) == string(base) {
+		return false, nil
+	}
+
+	// The lease is the head as looked up before the change: an empty one makes sure the branch was not created
+	// in the meantime, and the commit of an owned branch makes sure nobody pushed to it since
+	push := []string{"git", "push", "--quiet", "--force-with-lease=" + ref + ":" + remote, "origin", "HEAD:" + ref}
+	err = retry.do(ctx, "Pushing "+details.Head+" to "+details.Repo, func(ctx context.Context) error {
+		_, err := runCommandIn(ctx, workspace, nil, push...)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to push %s: %w", details.Head, err)
+	}
+	return true, nil
+}
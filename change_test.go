@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newOriginRepo creates a bare repository with a main branch and returns its path
+func newOriginRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	origin, work := filepath.Join(dir, "origin.git"), filepath.Join(dir, "work")
+	for _, args := range [][]string{
+		{"git", "init", "--quiet", "--bare", "--initial-branch", "main", origin},
+		{"git", "clone", "--quiet", origin, work},
+		{"sh", "-c", "cd " + work + " && echo 'go 1.21' > go.mod && git add go.mod && " +
+			"git -c user.name=Test -c user.email=test@example.com commit --quiet -m init && git push --quiet origin HEAD:main"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+	return origin
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCreatePullRequestWithScript(t *testing.T) {
+	origin := newOriginRepo(t)
+	originalMockCloneURL := mockCloneURL
+	defer func() { mockCloneURL = originalMockCloneURL }()
+	mockCloneURL = func(details Repo) string { return "file://" + origin }
+	be := useMemoryBackend(t)
+
+	config := &Config{
+		Defaults: Repo{Base: "main", Title: "Bump Go", Author: "Bulk Bot <bot@example.com>", CommitMessage: "Bump Go in {{ .Name }}"},
+		Repos: map[string]Repo{
+			"bump":    {Repo: "org/a", Head: "bump-go", Script: `sed -i.bak "s/1.21/{{ .Vars.go }}/" go.mod && rm go.mod.bak && echo "$BULKPR_REPO" > repo.txt`, Vars: map[string]string{"go": "1.22"}},
+			"noop":    {Repo: "org/a", Head: "noop", Script: "true"},
+			"failing": {Repo: "org/a", Head: "failing", Script: "echo broken >&2; exit 3"},
+		},
+	}
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, false) })
+	if err == nil || !strings.Contains(err.Error(), "script failed") {
+		t.Errorf("Expected the failing script to fail the run, got %v", err)
+	}
+	if !strings.Contains(output, "No changes for noop, skipping") {
+		t.Errorf("Expected the entry without changes to be skipped, got %q", output)
+	}
+
+	if got := gitOutput(t, origin, "log", "-1", "--format=%an <%ae>|%s", "bump-go"); got != "Bulk Bot <bot@example.com>|Bump Go in a" {
+		t.Errorf("Unexpected commit: %s", got)
+	}
+	if got := gitOutput(t, origin, "show", "bump-go:go.mod"); got != "go 1.22" {
+		t.Errorf("Unexpected go.mod: %s", got)
+	}
+	if got := gitOutput(t, origin, "show", "bump-go:repo.txt"); got != "org/a" {
+		t.Errorf("Expected the script environment, got %s", got)
+	}
+	if branches := gitOutput(t, origin, "branch", "--list", "noop", "failing"); branches != "" {
+		t.Errorf("Expected no other branch to be pushed, got %s", branches)
+	}
+	if len(be.prs) != 1 || be.prs[0].Head != "bump-go" {
+		t.Errorf("Expected a single PR for bump-go, got %v", be.calls)
+	}
+}
+
+func TestCreatePreparedReturnsEntriesWithPR(t *testing.T) {
+	origin := newOriginRepo(t)
+	originalMockCloneURL := mockCloneURL
+	defer func() { mockCloneURL = originalMockCloneURL }()
+	mockCloneURL = func(details Repo) string { return "file://" + origin }
+	useMemoryBackend(t)
+
+	// Entries without changes have no PR to wait for, unlike those skipped because of an open PR
+	config := &Config{
+		Defaults:   Repo{Base: "main", Title: "Change", Author: "Bulk Bot <bot@example.com>"},
+		OnExisting: existingSkip,
+		Repos: map[string]Repo{
+			"change": {Repo: "org/a", Head: "change", Script: "echo x > x.txt"},
+			"noop":   {Repo: "org/a", Head: "noop", Script: "true"},
+		},
+	}
+	for run := 1; run <= 2; run++ {
+		repoNames, prepared, err := prepareCampaign(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var withPR map[string]Repo
+		captureStdout(t, func() { withPR, err = createPrepared(config, repoNames, prepared, false) })
+		if _, ok := withPR["change"]; err != nil || len(withPR) != 1 || !ok {
+			t.Errorf("Run %d: unexpected entries with a PR: %v, %v", run, withPR, err)
+		}
+	}
+}
+
+func TestApplyChangeReplacesHead(t *testing.T) {
+	origin := newOriginRepo(t)
+	originalMockCloneURL := mockCloneURL
+	defer func() { mockCloneURL = originalMockCloneURL }()
+	mockCloneURL = func(details Repo) string { return "file://" + origin }
+
+	// A head branch left behind by a run whose PR creation failed is regenerated from base
+	ctx := context.Background()
+	retry := retrier{timeout: defaultCommandTimeout}
+	details := Repo{Repo: "org/a", Base: "main", Head: "change", Title: "Change", Author: "Bot <bot@example.com>"}
+	for i := 0; i < 2; i++ {
+		details.Script = fmt.Sprintf("echo %d > run.txt", i)
+		changed, err := applyChange(ctx, retry, "a", details, i > 0)
+		if err != nil || !changed {
+			t.Fatalf("Run %d: expected a change, got %v, %v", i, changed, err)
+		}
+	}
+	if got := gitOutput(t, origin, "rev-list", "--count", "main..change"); got != "1" {
+		t.Errorf("Expected the head branch to be regenerated from base, got %s commits", got)
+	}
+	if got := gitOutput(t, origin, "show", "change:run.txt"); got != "1" {
+		t.Errorf("Expected the change of the last run, got %s", got)
+	}
+
+	// A head branch that moves while the change is made is not overwritten
+	details.Script = "echo 2 > run.txt && git -C " + origin + " branch -f change main"
+	if _, err := applyChange(ctx, retry, "a", details, true); err == nil || !strings.Contains(err.Error(), "failed to push") {
+		t.Errorf("Expected a push error, got %v", err)
+	}
+
+	// A head branch the campaign does not own is left alone
+	details.Script = "echo 3 > run.txt"
+	if _, err := applyChange(ctx, retry, "a", details, false); classOf(err) != classAlreadyExists {
+		t.Errorf("Expected an existing branch error, got %v", err)
+	}
+	if got := gitOutput(t, origin, "rev-parse", "change"); got != gitOutput(t, origin, "rev-parse", "main") {
+		t.Errorf("Expected the branch not to be pushed, got %s", got)
+	}
+}
+
+func TestApplyChangeCommittingScript(t *testing.T) {
+	origin := newOriginRepo(t)
+	originalMockCloneURL := mockCloneURL
+	defer func() { mockCloneURL = originalMockCloneURL }()
+	mockCloneURL = func(details Repo) string { return "file://" + origin }
+
+	// Commits made by the script are the change, even if it leaves the worktree clean
+	ctx := context.Background()
+	retry := retrier{timeout: defaultCommandTimeout}
+	details := Repo{Repo: "org/a", Base: "main", Head: "committed", Title: "Change", Author: "Bot <bot@example.com>",
+		Script: "echo x > x.txt && git add x.txt && git -c user.name=Script -c user.email=s@example.com commit --quiet -m 'Add x'"}
+	changed, err := applyChange(ctx, retry, "a", details, false)
+	if err != nil || !changed {
+		t.Fatalf("Expected a change, got %v, %v", changed, err)
+	}
+	if got := gitOutput(t, origin, "log", "-1", "--format=%s", "committed"); got != "Add x" {
+		t.Errorf("Expected the commit of the script, got %s", got)
+	}
+
+	details.Head, details.Script = "clean", "true"
+	if changed, err := applyChange(ctx, retry, "a", details, false); err != nil || changed {
+		t.Errorf("Expected no change, got %v, %v", changed, err)
+	}
+}
+
+func TestCloneURL(t *testing.T) {
+	t.Setenv("GH_HOST", "")
+	tests := []struct {
+		details Repo
+		want    string
+	}{
+		{Repo{Repo: "org/a"}, "https://github.com/org/a.git"},
+		{Repo{Repo: "org/a", Host: "ghe.example.com"}, "https://ghe.example.com/org/a.git"},
+		{Repo{Repo: "group/sub/a", Provider: providerGitLab}, "https://gitlab.com/group/sub/a.git"},
+		{Repo{Repo: "org/a", Provider: providerForgejo, Host: "codeberg.org"}, "https://codeberg.org/org/a.git"},
+		{Repo{Repo: "PROJ/a", Provider: providerBitbucket, Host: "bitbucket.example.com"}, "https://bitbucket.example.com/scm/PROJ/a.git"},
+	}
+	for _, tt := range tests {
+		if got, err := cloneURL(tt.details); err != nil || got != tt.want {
+			t.Errorf("cloneURL(%+v) = %s, %v; expected %s", tt.details, got, err, tt.want)
+		}
+	}
+	if _, err := cloneURL(Repo{Repo: "PROJ/a", Provider: providerBitbucket}); classOf(err) != classValidation {
+		t.Errorf("Expected a validation error without a Bitbucket host, got %v", err)
+	}
+}
+
+func TestParseAuthor(t *testing.T) {
+	if name, email, err := parseAuthor("Bulk Bot <bot@example.com>"); err != nil || name != "Bulk Bot" || email != "bot@example.com" {
+		t.Errorf("Unexpected author: %s, %s, %v", name, email, err)
+	}
+	for _, author := range []string{"bot@example.com", "Bulk Bot", "<bot@example.com>"} {
+		if _, _, err := parseAuthor(author); err == nil {
+			t.Errorf("Expected an error for %q", author)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// statusClosed is the status of an entry whose pull request was closed by the close command
+const statusClosed = "closed"
+
+// commenter is implemented by backends that can comment on pull requests
+type commenter interface {
+	// commentPullRequest adds a comment with body to pr
+	commentPullRequest(ctx context.Context, details Repo, pr *pullRequest, body string) error
+}
+
+// closeOptions are the settings of the close command
+type closeOptions struct {
+	comment      string // Posted on every pull request before closing it, if set
+	deleteBranch bool
+	dryRun       bool
+}
+
+func runClose(args []string) error {
+	fs := newCommandFlagSet("close", "Close the open PR of every entry without merging it, e.g. when a rollout is cancelled.")
+	flags := addCampaignFlags(fs)
+	comment := fs.String("comment", "", "Comment explaining why the PRs are closed, as text or the path of a file")
+	deleteBranch := fs.Bool("delete-branch", false, "Delete the head branch after closing")
+	dryRun := fs.Bool("dry-run", false, "Show which PRs would be closed without closing them")
+	config, err := loadCampaign(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	body, err := textOrFile(*comment)
+	if err != nil {
+		return err
+	}
+	return closePullRequests(config, closeOptions{comment: body, deleteBranch: *deleteBranch, dryRun: *dryRun})
+}
+
+// closePullRequests closes the open pull request of every entry.
+// Pull requests that were already merged or closed are left alone and reported as such.
+func closePullRequests(config *Config, opts closeOptions) error {
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return err
+	}
+
+	retry := newRetrier(config)
+	stdout := progressOutput(config.Output)
+	rep := newReporter(os.Stdout, config.Output)
+
+	closeRepo := func(repoName string, details Repo) result {
+		res := newResult(repoName, details)
+		ctx := context.Background()
+
+		var pr *pullRequestStatus
+		err := retry.do(ctx, "Looking up PR for "+repoName, func(ctx context.Context) (err error) {
+			pr, err = lookupPullRequest(ctx, config, details)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to look up PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to look up PR for %s: %w", repoName, err))
+		}
+		if pr == nil {
+			fmt.Fprintf(stdout, "No PR found for %s, skipping\n", repoName)
+			return res.finish(statusNotFound, nil)
+		}
+		res.Number, res.URL = pr.Number, pr.URL
+
+		switch pr.State {
+		case prStateMerged:
+			fmt.Fprintf(stdout, "PR for %s was already merged (%s), skipping\n", repoName, pr.URL)
+			return res.finish(statusMerged, nil)
+		case prStateClosed:
+			fmt.Fprintf(stdout, "PR for %s is already closed (%s), skipping\n", repoName, pr.URL)
+			return res.finish(statusSkipped, nil)
+		}
+
+		if opts.dryRun {
+			fmt.Fprintf(stdout, "DRY RUN: Would close PR for %s (%s)\n", repoName, pr.URL)
+			return res.finish(statusDryRun, nil)
+		}
+
+		be, err := backendFor(config, details)
+		if err != nil {
+			return res.finish(statusFailed, fmt.Errorf("%s: %w", repoName, err))
+		}
+		if opts.comment != "" {
+			cm, err := backendWith[commenter](config, details, "commenting")
+			if err == nil {
+				err = retry.do(ctx, "Commenting on PR for "+repoName, func(ctx context.Context) error {
+					return cm.commentPullRequest(ctx, details, &pr.pullRequest, opts.comment)
+				})
+			}
+			if err != nil {
+				log.Printf("Failed to comment on PR for %s: %v\n", repoName, err)
+				return res.finish(statusFailed, fmt.Errorf("failed to comment on PR for %s: %w", repoName, err))
+			}
+		}
+		err = retry.do(ctx, "Closing PR for "+repoName, func(ctx context.Context) error {
+			return be.closePullRequest(ctx, details, &pr.pullRequest)
+		})
+		if err != nil {
+			log.Printf("Failed to close PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to close PR for %s: %w", repoName, err))
+		}
+		fmt.Fprintf(stdout, "PR closed for %s (%s)\n", repoName, pr.URL)
+
+		if opts.deleteBranch {
+			if err := deleteHeadBranch(ctx, config, retry, details); err != nil {
+				log.Printf("Warning: PR for %s was closed but %v\n", repoName, err)
+				return res.finish(statusClosed, fmt.Errorf("PR was closed but %w", err))
+			}
+		}
+		return res.finish(statusClosed, nil)
+	}
+
+	forEachRepo(config.Concurrency, repoNames, prepared, func(repoName string, details Repo) {
+		rep.add(closeRepo(repoName, details))
+	})
+	if err := rep.finish(); err != nil {
+		log.Printf("Failed to write report: %v\n", err)
+	}
+
+	counts := map[string]int{}
+	var failures []result
+	for _, res := range rep.results {
+		counts[res.Status]++
+		if res.Status == statusFailed {
+			failures = append(failures, res)
+		}
+	}
+	closed := counts[statusClosed]
+	if opts.dryRun {
+		closed = counts[statusDryRun]
+	}
+	fmt.Fprintf(stdout, "Closed: %d, already merged: %d, already closed: %d, not found: %d, failed: %d\n",
+		closed, counts[statusMerged], counts[statusSkipped], counts[statusNotFound], counts[statusFailed])
+
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Key < failures[j].Key })
+		for _, res := range failures {
+			log.Printf("  %s [%s] %s\n", res.Key, res.ErrorClass, res.Error)
+		}
+		return fmt.Errorf("failed to close one or more pull requests (first error: %w)", failures[0].err)
+	}
+	return nil
+}
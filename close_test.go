@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClosePullRequests(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/open", Base: "main", Head: "dev"})
+	be.add(Repo{Repo: "org/merged", Base: "main", Head: "dev"}).Merged = true
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+		"open":    {Repo: "org/open", Base: "main", Head: "dev"},
+		"merged":  {Repo: "org/merged", Base: "main", Head: "dev"},
+		"missing": {Repo: "org/missing", Base: "main", Head: "dev"},
+	}}
+	output := captureStdout(t, func() {
+		if err := closePullRequests(config, closeOptions{comment: "Rollout cancelled", deleteBranch: true}); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+
+	if be.find(Repo{Repo: "org/open", Base: "main", Head: "dev"}) != nil {
+		t.Error("Expected the open PR to be closed")
+	}
+	if got := be.comments[1]; len(got) != 1 || got[0] != "Rollout cancelled" {
+		t.Errorf("Expected the comment on the closed PR, got %v", got)
+	}
+	if len(be.comments[2]) != 0 {
+		t.Errorf("Expected no comment on the merged PR, got %v", be.comments[2])
+	}
+	if strings.Join(be.deleted, " ") != "org/open:dev" {
+		t.Errorf("Expected only the closed PR's branch to be deleted, got %v", be.deleted)
+	}
+	if !strings.Contains(output, "Closed: 1, already merged: 1, already closed: 0, not found: 1, failed: 0") {
+		t.Errorf("Expected a summary, got:\n%s", output)
+	}
+}
+
+func TestClosePullRequestsDryRun(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/open", Base: "main", Head: "dev"})
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{"open": {Repo: "org/open", Base: "main", Head: "dev"}}}
+	output := captureStdout(t, func() {
+		if err := closePullRequests(config, closeOptions{comment: "why", dryRun: true}); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "DRY RUN: Would close PR for open (https://forge.test/org/open/pull/1)") || !strings.Contains(output, "Closed: 1,") {
+		t.Errorf("Unexpected dry run output:\n%s", output)
+	}
+	if be.find(Repo{Repo: "org/open", Base: "main", Head: "dev"}) == nil || len(be.comments) != 0 {
+		t.Error("Expected nothing to change in a dry run")
+	}
+}
+
+func TestLookupPullRequestWithoutStatusSupport(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	// Hide pullRequestStatus, so that only open pull requests can be found
+	mockBackend = struct{ backend }{be}
+
+	pr, err := lookupPullRequest(context.Background(), &Config{}, Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	if err != nil || pr == nil || pr.Number != 1 || pr.State != prStateOpen {
+		t.Errorf("Expected open PR 1, got %+v, %v", pr, err)
+	}
+	if fmt.Sprint(be.calls) != "[find org/r]" {
+		t.Errorf("Expected the PR to be found with findPullRequest, got %v", be.calls)
+	}
+}
+
+func TestTextOrFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comment.md")
+	os.WriteFile(path, []byte("from file"), 0o644)
+
+	if got, err := textOrFile(path); err != nil || got != "from file" {
+		t.Errorf("textOrFile(file) = %q, %v", got, err)
+	}
+	if got, err := textOrFile("plain text"); err != nil || got != "plain text" {
+		t.Errorf("textOrFile(text) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// command is a subcommand run as `bulkpr <name> [flags] <config-file>...`
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+// commands are the subcommands besides the default of creating pull requests
+var commands = map[string]command{
+	"status":   {"Show the state, reviews, checks and mergeability of every PR in a campaign", runStatus},
+	"merge":    {"Merge the PRs of a campaign that are approved and have passing checks", runMerge},
+	"close":    {"Close the open PRs of a campaign, e.g. when a rollout is cancelled", runClose},
+	"comment":  {"Post a comment on every open PR of a campaign, once", runComment},
+	"init":     {"Write a starter configuration with one entry per repository of an organization or user", runInit},
+	"generate": {"Alias of init", runInit},
+	"validate": {"Check a campaign without creating anything, with --remote also against the forge", runValidate},
+	"watch":    {"Wait until the checks of every PR in a campaign completed and report the outcome", runWatch},
+}
+
+// printCommands lists the subcommands for --help
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("\nCommands:")
+	for _, name := range names {
+		fmt.Printf("  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// campaignFlags are the flags shared by every command that works on a campaign
+type campaignFlags struct {
+	concurrency *int
+	retries     *int
+	timeout     *time.Duration
+	output      *string
+	backend     *string
+}
+
+// addCampaignFlags registers the shared campaign flags on fs
+func addCampaignFlags(fs *flag.FlagSet) *campaignFlags {
+	return &campaignFlags{
+		concurrency: fs.Int("concurrency", 0, fmt.Sprintf("Maximum number of repositories processed at the same time (default %d)", defaultConcurrency)),
+		retries:     fs.Int("retries", -1, fmt.Sprintf("Number of retries for transient failures such as rate limits and network errors (default %d)", defaultRetries)),
+		timeout:     fs.Duration("timeout", 0, fmt.Sprintf("Timeout for each gh command (default %s)", defaultCommandTimeout)),
+		output:      fs.String("output", "", "Report format: text, json or ndjson (default text)"),
+		backend:     fs.String("backend", "", "How to reach GitHub: gh (the gh CLI) or api (the REST API, using GH_TOKEN and GH_HOST) (default gh)"),
+	}
+}
+
+// apply overrides the configuration with the flags that were set
+func (f *campaignFlags) apply(config *Config) {
+	if *f.concurrency > 0 {
+		config.Concurrency = *f.concurrency
+	}
+	if *f.retries >= 0 {
+		config.Retries = f.retries
+	}
+	if *f.timeout > 0 {
+		config.Timeout = *f.timeout
+	}
+	if *f.output != "" {
+		config.Output = *f.output
+	}
+	if *f.backend != "" {
+		config.Backend = *f.backend
+	}
+}
+
+// loadCampaign parses the arguments of a command and reads the configuration files they name
+func loadCampaign(fs *flag.FlagSet, flags *campaignFlags, args []string) (*Config, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < 1 {
+		return nil, fmt.Errorf("usage: bulkpr %s [flags] <config-file1> [config-file2] ...", fs.Name())
+	}
+
+	config, err := readYAMLConfig(fs.Args())
+	if err != nil {
+		return nil, fmt.Errorf("error reading config files: %w", err)
+	}
+	flags.apply(config)
+
+	if err := validateOutputFormat(config.Output); err != nil {
+		return nil, err
+	}
+	if err := validateBackend(config.Backend); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// runCommandLine runs the subcommand named by args[0], reporting whether there was one
+func runCommandLine(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		logFatalf("Error running %s: %v", args[0], err)
+	}
+	return true
+}
+
+// newCommandFlagSet returns the flag set of a subcommand
+func newCommandFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gh bulkpr %s [flags] <config-file1> [config-file2] ...\n%s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// stringList is a flag that can be repeated, e.g. --topic go --topic service
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// statusCommented is the status of an entry whose pull request received the comment
+const statusCommented = "commented"
+
+// commentLister is implemented by backends that can list the comments of pull requests
+type commentLister interface {
+	// listComments returns the bodies of the comments on pr
+	listComments(ctx context.Context, details Repo, pr *pullRequest) ([]string, error)
+}
+
+// commentOptions are the settings of the comment command
+type commentOptions struct {
+	body   string // Comment template, rendered for every entry like `body`
+	marker string // Identifies the comment so it is posted only once; derived from body when empty
+	dryRun bool
+}
+
+// commentMarkerPattern restricts markers to characters that are safe inside an HTML comment
+var commentMarkerPattern = regexp.MustCompile(`^[A-Za-z0-9._:/-]+$`)
+
+// commentMarker returns the hidden line appended to comments to recognize them later
+func commentMarker(marker string) string {
+	return "<!-- bulkpr:" + marker + " -->"
+}
+
+// defaultCommentMarker derives a marker from the unrendered comment,
+// so the same comment gets the same marker on every pull request of a campaign
+func defaultCommentMarker(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func runComment(args []string) error {
+	fs := newCommandFlagSet("comment", "Post a comment on the open PR of every entry, once.\nThe comment is a template with the same data as `body`.")
+	flags := addCampaignFlags(fs)
+	body := fs.String("body", "", "Comment to post, as text or the path of a file (required)")
+	marker := fs.String("marker", "", "Identifier of the comment used to avoid posting it twice (default: derived from the comment)")
+	dryRun := fs.Bool("dry-run", false, "Show the comments without posting them")
+	config, err := loadCampaign(fs, flags, args)
+	if err != nil {
+		return err
+	}
+
+	text, err := textOrFile(*body)
+	if err != nil {
+		return err
+	}
+	return commentPullRequests(config, commentOptions{body: text, marker: *marker, dryRun: *dryRun})
+}
+
+// commentPullRequests posts the rendered comment on the open pull request of every entry,
+// skipping pull requests that already have a comment with the same marker
+func commentPullRequests(config *Config, opts commentOptions) error {
+	if strings.TrimSpace(opts.body) == "" {
+		return fmt.Errorf("a comment is required (--body)")
+	}
+	if opts.marker == "" {
+		opts.marker = defaultCommentMarker(opts.body)
+	}
+	if !commentMarkerPattern.MatchString(opts.marker) {
+		return fmt.Errorf("invalid marker %q, only letters, digits and . _ : / - are allowed", opts.marker)
+	}
+	marker := commentMarker(opts.marker)
+
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return err
+	}
+
+	// Render every comment before posting any, like the PR templates
+	var templateErrs []error
+	comments := make(map[string]string, len(prepared))
+	for repoName, details := range prepared {
+		rendered, err := renderTemplate("comment", opts.body, newTemplateData(repoName, details))
+		if err != nil {
+			templateErrs = append(templateErrs, fmt.Errorf("repo %s: invalid comment template: %w", repoName, err))
+			continue
+		}
+		comments[repoName] = rendered + "\n\n" + marker
+	}
+	if len(templateErrs) > 0 {
+		return fmt.Errorf("failed to render the comment, nothing was posted: %w", errors.Join(templateErrs...))
+	}
+
+	retry := newRetrier(config)
+	stdout := progressOutput(config.Output)
+	rep := newReporter(os.Stdout, config.Output)
+
+	commentRepo := func(repoName string, details Repo) result {
+		res := newResult(repoName, details)
+		ctx := context.Background()
+
+		var pr *pullRequestStatus
+		err := retry.do(ctx, "Looking up PR for "+repoName, func(ctx context.Context) (err error) {
+			pr, err = lookupPullRequest(ctx, config, details)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to look up PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to look up PR for %s: %w", repoName, err))
+		}
+		if pr == nil {
+			fmt.Fprintf(stdout, "No PR found for %s, skipping\n", repoName)
+			return res.finish(statusNotFound, nil)
+		}
+		res.Number, res.URL = pr.Number, pr.URL
+		if pr.State == prStateMerged || pr.State == prStateClosed {
+			fmt.Fprintf(stdout, "PR for %s is %s (%s), skipping\n", repoName, pr.State, pr.URL)
+			return res.finish(statusSkipped, nil)
+		}
+
+		lister, err := backendWith[commentLister](config, details, "listing comments")
+		if err != nil {
+			return res.finish(statusFailed, fmt.Errorf("%s: %w", repoName, err))
+		}
+		var existing []string
+		err = retry.do(ctx, "Listing comments of PR for "+repoName, func(ctx context.Context) (err error) {
+			existing, err = lister.listComments(ctx, details, &pr.pullRequest)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to list comments of PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to list comments of PR for %s: %w", repoName, err))
+		}
+		for _, body := range existing {
+			if strings.Contains(body, marker) {
+				fmt.Fprintf(stdout, "PR for %s already has the comment (%s), skipping\n", repoName, pr.URL)
+				return res.finish(statusSkipped, nil)
+			}
+		}
+
+		if opts.dryRun {
+			fmt.Fprintf(stdout, "DRY RUN: Would comment on PR for %s (%s):\n%s\n", repoName, pr.URL, comments[repoName])
+			return res.finish(statusDryRun, nil)
+		}
+
+		cm, err := backendWith[commenter](config, details, "commenting")
+		if err == nil {
+			err = retry.do(ctx, "Commenting on PR for "+repoName, func(ctx context.Context) error {
+				return cm.commentPullRequest(ctx, details, &pr.pullRequest, comments[repoName])
+			})
+		}
+		if err != nil {
+			log.Printf("Failed to comment on PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to comment on PR for %s: %w", repoName, err))
+		}
+		fmt.Fprintf(stdout, "Commented on PR for %s (%s)\n", repoName, pr.URL)
+		return res.finish(statusCommented, nil)
+	}
+
+	forEachRepo(config.Concurrency, repoNames, prepared, func(repoName string, details Repo) {
+		rep.add(commentRepo(repoName, details))
+	})
+	if err := rep.finish(); err != nil {
+		log.Printf("Failed to write report: %v\n", err)
+	}
+
+	var failures []result
+	for _, res := range rep.results {
+		if res.Status == statusFailed {
+			failures = append(failures, res)
+		}
+	}
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Key < failures[j].Key })
+		for _, res := range failures {
+			log.Printf("  %s [%s] %s\n", res.Key, res.ErrorClass, res.Error)
+		}
+		return fmt.Errorf("failed to comment on one or more pull requests (first error: %w)", failures[0].err)
+	}
+	return nil
+}
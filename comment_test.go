@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCommentPullRequests(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/a", Base: "main", Head: "dev"})
+	be.add(Repo{Repo: "org/b", Base: "main", Head: "dev"}).Merged = true
+
+	newConfig := func() *Config {
+		return &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+			"a": {Repo: "org/a", Base: "main", Head: "dev", Vars: map[string]string{"team": "core"}},
+			"b": {Repo: "org/b", Base: "main", Head: "dev", Vars: map[string]string{"team": ""}},
+		}}
+	}
+	opts := commentOptions{body: `Please rebase {{ .Name }} ({{ .Vars.team | default "all" }})`}
+
+	for run := 0; run < 2; run++ {
+		captureStdout(t, func() {
+			if err := commentPullRequests(newConfig(), opts); err != nil {
+				t.Errorf("Run %d: expected nil error, got %v", run, err)
+			}
+		})
+	}
+
+	comments := be.comments[1]
+	if len(comments) != 1 {
+		t.Fatalf("Expected exactly one comment after two runs, got %v", comments)
+	}
+	if !strings.HasPrefix(comments[0], "Please rebase a (core)\n\n<!-- bulkpr:") {
+		t.Errorf("Unexpected comment: %q", comments[0])
+	}
+	if len(be.comments[2]) != 0 {
+		t.Errorf("Expected no comment on the merged PR, got %v", be.comments[2])
+	}
+
+	// A different marker posts the comment again
+	captureStdout(t, func() {
+		opts.marker = "freeze-lifted"
+		if err := commentPullRequests(newConfig(), opts); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if len(be.comments[1]) != 2 || !strings.HasSuffix(be.comments[1][1], "<!-- bulkpr:freeze-lifted -->") {
+		t.Errorf("Expected a second comment with the new marker, got %v", be.comments[1])
+	}
+}
+
+func TestCommentPullRequestsValidation(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/a", Base: "main", Head: "dev"})
+	config := func() *Config {
+		return &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{"a": {Repo: "org/a", Base: "main", Head: "dev"}}}
+	}
+
+	if err := commentPullRequests(config(), commentOptions{}); err == nil {
+		t.Error("Expected an error without a comment")
+	}
+	if err := commentPullRequests(config(), commentOptions{body: "x", marker: "a -->"}); err == nil {
+		t.Error("Expected an error for an invalid marker")
+	}
+	if err := commentPullRequests(config(), commentOptions{body: "{{ .Vars.missing }}"}); err == nil || !strings.Contains(err.Error(), "nothing was posted") {
+		t.Errorf("Expected a template error, got %v", err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := commentPullRequests(config(), commentOptions{body: "hello", dryRun: true}); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "DRY RUN: Would comment on PR for a") || len(be.comments) != 0 {
+		t.Errorf("Expected a dry run without comments, got %q and %v", output, be.comments)
+	}
+}
+
+func TestGHBackendListComments(t *testing.T) {
+	originalMockRunCommandOutput := mockRunCommandOutput
+	defer func() { mockRunCommandOutput = originalMockRunCommandOutput }()
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		return []byte(`{"comments": [{"body": "first"}, {"body": "second <!-- bulkpr:x -->"}]}`), nil
+	}
+
+	bodies, err := ghBackend{}.listComments(context.Background(), Repo{Repo: "org/r"}, &pullRequest{Number: 1})
+	if err != nil || len(bodies) != 2 || bodies[1] != "second <!-- bulkpr:x -->" {
+		t.Errorf("Unexpected comments: %v, %v", bodies, err)
+	}
+}
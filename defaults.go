@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// List merge modes for combining an entry's lists with the defaults
+const (
+	listMergeAppend  = "append"
+	listMergeReplace = "replace"
+)
+
+// UnmarshalYAML decodes a Repo and remembers whether `draft` was set explicitly,
+// so that `draft: false` on an entry can override `draft: true` in the defaults.
+func (r *Repo) UnmarshalYAML(node *yaml.Node) error {
+	type plainRepo Repo
+	if err := node.Decode((*plainRepo)(r)); err != nil {
+		return err
+	}
+
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "draft" {
+				r.draftSet = true
+			}
+		}
+	}
+	return nil
+}
+
+// mergeRepo returns entry with every unset field taken from defaults.
+// Scalars in entry win; lists are appended to the defaults unless entry sets
+// `list_merge: replace`, in which case a non-empty list in entry replaces them.
+func mergeRepo(defaults, entry Repo) (Repo, error) {
+	mode := entry.ListMerge
+	if mode == "" {
+		mode = defaults.ListMerge
+	}
+	if mode == "" {
+		mode = listMergeAppend
+	}
+	if mode != listMergeAppend && mode != listMergeReplace {
+		return entry, fmt.Errorf("invalid list_merge %q, expected %q or %q", mode, listMergeAppend, listMergeReplace)
+	}
+
+	merged := entry
+	merged.ListMerge = mode
+	merged.Repo = mergeString(defaults.Repo, entry.Repo)
+	merged.Base = mergeString(defaults.Base, entry.Base)
+	merged.Head = mergeString(defaults.Head, entry.Head)
+	merged.Title = mergeString(defaults.Title, entry.Title)
+	merged.Body = mergeString(defaults.Body, entry.Body)
+	merged.Provider = mergeString(defaults.Provider, entry.Provider)
+	merged.Host = mergeString(defaults.Host, entry.Host)
+	merged.AutoMerge = mergeString(defaults.AutoMerge, entry.AutoMerge)
+	merged.Script = mergeString(defaults.Script, entry.Script)
+	merged.CommitMessage = mergeString(defaults.CommitMessage, entry.CommitMessage)
+	merged.Author = mergeString(defaults.Author, entry.Author)
+	if merged.MergeOrder == 0 {
+		merged.MergeOrder = defaults.MergeOrder
+	}
+	merged.Labels = mergeList(defaults.Labels, entry.Labels, mode)
+	merged.Assignees = mergeList(defaults.Assignees, entry.Assignees, mode)
+	merged.Reviewers = mergeList(defaults.Reviewers, entry.Reviewers, mode)
+	if len(entry.Edits) == 0 {
+		merged.Edits = defaults.Edits
+	} else if mode == listMergeAppend && len(defaults.Edits) > 0 {
+		merged.Edits = append(append([]fileEdit(nil), defaults.Edits...), entry.Edits...)
+	}
+
+	if !entry.Draft && !entry.draftSet {
+		merged.Draft = defaults.Draft
+		merged.draftSet = defaults.draftSet
+	}
+
+	if len(defaults.Vars) > 0 {
+		merged.Vars = make(map[string]string, len(defaults.Vars)+len(entry.Vars))
+		for k, v := range defaults.Vars {
+			merged.Vars[k] = v
+		}
+		for k, v := range entry.Vars {
+			merged.Vars[k] = v
+		}
+	}
+
+	return merged, nil
+}
+
+func mergeString(def, value string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+func mergeList(def, value []string, mode string) []string {
+	if len(def) == 0 {
+		return value
+	}
+	if len(value) == 0 {
+		return append([]string(nil), def...)
+	}
+	if mode == listMergeReplace {
+		return value
+	}
+
+	merged := append([]string(nil), def...)
+	seen := make(map[string]bool, len(def)+len(value))
+	for _, v := range def {
+		seen[v] = true
+	}
+	for _, v := range value {
+		if !seen[v] {
+			seen[v] = true
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+// applyDefaults merges the `defaults:` block into every repository entry
+func applyDefaults(config *Config) error {
+	for key, entry := range config.Repos {
+		merged, err := mergeRepo(config.Defaults, entry)
+		if err != nil {
+			return fmt.Errorf("repo %s: %w", key, err)
+		}
+		config.Repos[key] = merged
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadYAMLConfigWithDefaults(t *testing.T) {
+	file := createTempYAMLFile(t, `
+defaults:
+  base: "main"
+  head: "release-prep"
+  title: "Release prep"
+  body: "Default body"
+  labels: ["release"]
+  reviewers: ["org/release-team"]
+  draft: true
+  vars: {version: "v2", team: "platform"}
+repos:
+  inherit:
+    repo: "org/inherit"
+  override:
+    repo: "org/override"
+    base: "develop"
+    labels: ["extra"]
+    draft: false
+    vars: {version: "v3"}
+  replace:
+    repo: "org/replace"
+    labels: ["only"]
+    list_merge: replace
+`)
+	defer os.Remove(file)
+
+	config, err := readYAMLConfig([]string{file})
+	if err != nil {
+		t.Fatalf("Failed to read YAML config: %v", err)
+	}
+	if err := applyDefaults(config); err != nil {
+		t.Fatalf("applyDefaults failed: %v", err)
+	}
+
+	inherit := config.Repos["inherit"]
+	if inherit.Base != "main" || inherit.Head != "release-prep" || inherit.Title != "Release prep" || inherit.Body != "Default body" {
+		t.Errorf("Expected scalars from defaults, got %+v", inherit)
+	}
+	if !inherit.Draft {
+		t.Errorf("Expected draft inherited from defaults")
+	}
+	if !equalSlices(inherit.Reviewers, []string{"org/release-team"}) {
+		t.Errorf("Expected reviewers from defaults, got %v", inherit.Reviewers)
+	}
+
+	override := config.Repos["override"]
+	if override.Base != "develop" {
+		t.Errorf("Expected base override, got %s", override.Base)
+	}
+	if override.Draft {
+		t.Errorf("Expected explicit draft: false to override defaults")
+	}
+	if !equalSlices(override.Labels, []string{"release", "extra"}) {
+		t.Errorf("Expected appended labels, got %v", override.Labels)
+	}
+	if override.Vars["version"] != "v3" || override.Vars["team"] != "platform" {
+		t.Errorf("Expected merged vars, got %v", override.Vars)
+	}
+
+	replace := config.Repos["replace"]
+	if !equalSlices(replace.Labels, []string{"only"}) {
+		t.Errorf("Expected replaced labels, got %v", replace.Labels)
+	}
+	if !equalSlices(replace.Reviewers, []string{"org/release-team"}) {
+		t.Errorf("Expected empty list to still inherit defaults, got %v", replace.Reviewers)
+	}
+}
+
+func TestMergeRepoAppendDeduplicates(t *testing.T) {
+	merged, err := mergeRepo(Repo{Labels: []string{"a", "b"}}, Repo{Labels: []string{"b", "c"}})
+	if err != nil {
+		t.Fatalf("mergeRepo failed: %v", err)
+	}
+	if !equalSlices(merged.Labels, []string{"a", "b", "c"}) {
+		t.Errorf("Expected deduplicated append, got %v", merged.Labels)
+	}
+}
+
+func TestMergeRepoInvalidListMerge(t *testing.T) {
+	_, err := mergeRepo(Repo{}, Repo{ListMerge: "prepend"})
+	if err == nil || !strings.Contains(err.Error(), "invalid list_merge") {
+		t.Errorf("Expected invalid list_merge error, got %v", err)
+	}
+}
+
+func TestCreatePullRequestUsesDefaults(t *testing.T) {
+	originalMockRunCommand := mockRunCommand
+	defer func() { mockRunCommand = originalMockRunCommand }()
+
+	var capturedArgs []string
+	mockRunCommand = func(args ...string) error {
+		capturedArgs = append([]string(nil), args...)
+		return nil
+	}
+
+	config := &Config{
+		Preflight: new(bool),
+		Defaults:  Repo{Base: "main", Head: "dev", Title: "T", Body: "B", Labels: []string{"fleet"}},
+		Repos:     map[string]Repo{"only-repo": {Repo: "org/only-repo"}},
+	}
+	if err := createPullRequest(config, false); err != nil {
+		t.Fatalf("Expected defaults to make the entry valid, got %v", err)
+	}
+	if !strings.Contains(strings.Join(capturedArgs, " "), "--base main --head dev --label fleet") {
+		t.Errorf("Expected defaults in command, got %v", capturedArgs)
+	}
+}
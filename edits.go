@@ -0,0 +1,677 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileEdit is a declarative change to the files of a repository. Exactly one operation is set.
+type fileEdit struct {
+	Replace    *replaceEdit    `yaml:"replace,omitempty"`
+	Set        *setEdit        `yaml:"set,omitempty"`
+	AddLine    *addLineEdit    `yaml:"add_line,omitempty"`
+	RemoveLine *removeLineEdit `yaml:"remove_line,omitempty"`
+	GoModule   *goModuleEdit   `yaml:"go_module,omitempty"`
+}
+
+// replaceEdit replaces every match of a regular expression in the files matching a glob
+type replaceEdit struct {
+	Files   string `yaml:"files"`   // Glob relative to the repository root, `**` matches any number of directories
+	Pattern string `yaml:"pattern"` // Regular expression
+	With    string `yaml:"with"`    // Replacement, may refer to groups as ${1}
+}
+
+// setEdit sets a key of a YAML or JSON file
+type setEdit struct {
+	File  string      `yaml:"file"`
+	Key   string      `yaml:"key"` // Dot-separated path, with numbers indexing lists, e.g. "spec.containers.0.image"
+	Value interface{} `yaml:"value"`
+}
+
+// addLineEdit adds a line to a file unless the file already has it
+type addLineEdit struct {
+	File  string `yaml:"file"`
+	Line  string `yaml:"line"`
+	After string `yaml:"after,omitempty"` // Regular expression of the line to insert after (default: at the end)
+}
+
+// removeLineEdit removes the lines of a file equal to Line or matching Pattern
+type removeLineEdit struct {
+	File    string `yaml:"file"`
+	Line    string `yaml:"line,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"`
+}
+
+// goModuleEdit changes the required version of a module in go.mod files
+type goModuleEdit struct {
+	Module  string `yaml:"module"`
+	Version string `yaml:"version"`
+	Files   string `yaml:"files,omitempty"` // Glob of the go.mod files (default: every go.mod)
+}
+
+// validate checks an edit before any repository is cloned
+func (e fileEdit) validate() error {
+	var ops []string
+	if e.Replace != nil {
+		ops = append(ops, "replace")
+		if e.Replace.Files == "" || e.Replace.Pattern == "" {
+			return fmt.Errorf("replace needs files and pattern")
+		}
+		if err := validateGlob(e.Replace.Files); err != nil {
+			return err
+		}
+		if _, err := regexp.Compile(e.Replace.Pattern); err != nil {
+			return fmt.Errorf("replace: invalid pattern: %w", err)
+		}
+	}
+	if e.Set != nil {
+		ops = append(ops, "set")
+		if e.Set.File == "" || e.Set.Key == "" {
+			return fmt.Errorf("set needs file and key")
+		}
+		if _, err := structuredFormat(e.Set.File); err != nil {
+			return err
+		}
+	}
+	if e.AddLine != nil {
+		ops = append(ops, "add_line")
+		if e.AddLine.File == "" || e.AddLine.Line == "" {
+			return fmt.Errorf("add_line needs file and line")
+		}
+		if _, err := regexp.Compile(e.AddLine.After); err != nil {
+			return fmt.Errorf("add_line: invalid after: %w", err)
+		}
+	}
+	if e.RemoveLine != nil {
+		ops = append(ops, "remove_line")
+		if e.RemoveLine.File == "" || (e.RemoveLine.Line == "") == (e.RemoveLine.Pattern == "") {
+			return fmt.Errorf("remove_line needs file and either line or pattern")
+		}
+		if _, err := regexp.Compile(e.RemoveLine.Pattern); err != nil {
+			return fmt.Errorf("remove_line: invalid pattern: %w", err)
+		}
+	}
+	if e.GoModule != nil {
+		ops = append(ops, "go_module")
+		if e.GoModule.Module == "" || e.GoModule.Version == "" {
+			return fmt.Errorf("go_module needs module and version")
+		}
+		if err := validateGlob(e.GoModule.Files); err != nil {
+			return err
+		}
+	}
+	if len(ops) != 1 {
+		return fmt.Errorf("an edit needs exactly one of replace, set, add_line, remove_line or go_module, got %d", len(ops))
+	}
+	return nil
+}
+
+// validateEdits checks every edit of an entry
+func validateEdits(edits []fileEdit) error {
+	for i, edit := range edits {
+		if err := edit.validate(); err != nil {
+			return fmt.Errorf("edit %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// renderEdits renders the replacement, value, line and version of every edit as templates
+func renderEdits(edits []fileEdit, data templateData) ([]fileEdit, error) {
+	if len(edits) == 0 {
+		return edits, nil
+	}
+	var err error
+	render := func(text string) string {
+		if err != nil {
+			return text
+		}
+		var rendered string
+		rendered, err = renderTemplate("edits", text, data)
+		return rendered
+	}
+
+	rendered := make([]fileEdit, len(edits))
+	for i, edit := range edits {
+		if edit.Replace != nil {
+			replace := *edit.Replace
+			replace.With = render(replace.With)
+			edit.Replace = &replace
+		}
+		if edit.Set != nil {
+			set := *edit.Set
+			if value, ok := set.Value.(string); ok {
+				set.Value = render(value)
+			}
+			edit.Set = &set
+		}
+		if edit.AddLine != nil {
+			addLine := *edit.AddLine
+			addLine.Line = render(addLine.Line)
+			edit.AddLine = &addLine
+		}
+		if edit.GoModule != nil {
+			goModule := *edit.GoModule
+			goModule.Version = render(goModule.Version)
+			edit.GoModule = &goModule
+		}
+		rendered[i] = edit
+	}
+	return rendered, err
+}
+
+// applyEdits applies edits in order to the working copy in dir
+func applyEdits(dir string, edits []fileEdit) error {
+	for i, edit := range edits {
+		var err error
+		switch {
+		case edit.Replace != nil:
+			err = edit.Replace.apply(dir)
+		case edit.Set != nil:
+			err = edit.Set.apply(dir)
+		case edit.AddLine != nil:
+			err = edit.AddLine.apply(dir)
+		case edit.RemoveLine != nil:
+			err = edit.RemoveLine.apply(dir)
+		case edit.GoModule != nil:
+			err = edit.GoModule.apply(dir)
+		}
+		if err != nil {
+			return fmt.Errorf("edit %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether the slash-separated path name matches pattern,
+// where `**` matches any number of directories
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// globFiles returns the regular files of the working copy in dir matching pattern, relative to dir
+func globFiles(dir, pattern string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); matchGlob(pattern, rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// editFile rewrites a file of the working copy with edit, keeping its permissions.
+// Missing files are left alone unless create is set, in which case edit gets empty content.
+func editFile(dir, name string, create bool, edit func(content []byte) ([]byte, error)) error {
+	p, err := workingCopyPath(dir, name)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o644)
+	content, err := os.ReadFile(p)
+	if os.IsNotExist(err) && create {
+		err = os.MkdirAll(filepath.Dir(p), 0o755)
+	} else if err == nil {
+		if info, statErr := os.Stat(p); statErr == nil {
+			mode = info.Mode().Perm()
+		}
+	} else if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	edited, err := edit(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if bytes.Equal(edited, content) && content != nil {
+		return nil
+	}
+	return os.WriteFile(p, edited, mode)
+}
+
+// workingCopyPath returns the path of the file name of the working copy in dir.
+// Symbolic links are refused, so that an edit never reads or writes outside of the working copy.
+func workingCopyPath(dir, name string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the repository", name)
+	}
+
+	// Check the file and every directory leading to it, up to the first one that doesn't exist yet
+	existing, walked := dir, ""
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		walked = path.Join(walked, part)
+		next := filepath.Join(existing, part)
+		info, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s: %s is a symbolic link", name, walked)
+		}
+		existing = next
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the repository", name)
+	}
+	return p, nil
+}
+
+func (e *replaceEdit) apply(dir string) error {
+	re := regexp.MustCompile(e.Pattern)
+	files, err := globFiles(dir, e.Files)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		err := editFile(dir, name, false, func(content []byte) ([]byte, error) {
+			return re.ReplaceAll(content, []byte(e.With)), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLines splits content into lines, reporting whether it ended with a newline
+func splitLines(content []byte) ([]string, bool) {
+	text := string(content)
+	if text == "" {
+		return nil, true
+	}
+	trailing := strings.HasSuffix(text, "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), trailing
+}
+
+func joinLines(lines []string, trailing bool) []byte {
+	text := strings.Join(lines, "\n")
+	if trailing && len(lines) > 0 {
+		text += "\n"
+	}
+	return []byte(text)
+}
+
+func (e *addLineEdit) apply(dir string) error {
+	return editFile(dir, e.File, true, func(content []byte) ([]byte, error) {
+		lines, trailing := splitLines(content)
+		for _, line := range lines {
+			if strings.TrimRight(line, "\r") == e.Line {
+				return content, nil
+			}
+		}
+
+		at := len(lines)
+		if e.After != "" {
+			re := regexp.MustCompile(e.After)
+			for i, line := range lines {
+				if re.MatchString(line) {
+					at = i + 1
+					break
+				}
+			}
+		}
+		lines = append(lines[:at], append([]string{e.Line}, lines[at:]...)...)
+		return joinLines(lines, trailing), nil
+	})
+}
+
+func (e *removeLineEdit) apply(dir string) error {
+	var re *regexp.Regexp
+	if e.Pattern != "" {
+		re = regexp.MustCompile(e.Pattern)
+	}
+	return editFile(dir, e.File, false, func(content []byte) ([]byte, error) {
+		lines, trailing := splitLines(content)
+		kept := lines[:0:0]
+		for _, line := range lines {
+			if (re != nil && re.MatchString(line)) || (re == nil && strings.TrimRight(line, "\r") == e.Line) {
+				continue
+			}
+			kept = append(kept, line)
+		}
+		return joinLines(kept, trailing), nil
+	})
+}
+
+// goModRequire matches a requirement in go.mod, on a `require` line or inside a `require (...)` block
+var goModRequire = regexp.MustCompile(`^(\s*(?:require\s+)?)(\S+)(\s+)(\S+)(.*)$`)
+
+func (e *goModuleEdit) apply(dir string) error {
+	pattern := e.Files
+	if pattern == "" {
+		pattern = "**/go.mod"
+	}
+	files, err := globFiles(dir, pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		err := editFile(dir, name, false, func(content []byte) ([]byte, error) {
+			lines, trailing := splitLines(content)
+			inBlock := false
+			for i, line := range lines {
+				trimmed := strings.TrimSpace(line)
+				switch {
+				case strings.HasPrefix(trimmed, "require ("):
+					inBlock = true
+					continue
+				case inBlock && strings.HasPrefix(trimmed, ")"):
+					inBlock = false
+					continue
+				case !inBlock && !strings.HasPrefix(trimmed, "require "):
+					continue
+				}
+				if m := goModRequire.FindStringSubmatch(line); m != nil && strings.Trim(m[2], `"`) == e.Module {
+					lines[i] = m[1] + m[2] + m[3] + e.Version + m[5]
+				}
+			}
+			return joinLines(lines, trailing), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Formats of the files the set edit supports
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+func structuredFormat(name string) (string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	case ".json":
+		return formatJSON, nil
+	}
+	return "", fmt.Errorf("set: unsupported file %s, expected a .yaml, .yml or .json file", name)
+}
+
+func (e *setEdit) apply(dir string) error {
+	format, err := structuredFormat(e.File)
+	if err != nil {
+		return err
+	}
+	keys := strings.Split(e.Key, ".")
+	return editFile(dir, e.File, false, func(content []byte) ([]byte, error) {
+		// JSON is parsed as YAML too, which keeps the order of the keys
+		var docs []*yaml.Node
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		for {
+			var doc yaml.Node
+			if err := dec.Decode(&doc); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			docs = append(docs, &doc)
+		}
+		if len(docs) == 0 {
+			docs = []*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}}
+		}
+		var value yaml.Node
+		if err := value.Encode(e.Value); err != nil {
+			return nil, err
+		}
+
+		// In a file of several documents, the key is set in the documents that already have it
+		targets := docs
+		if len(docs) > 1 {
+			targets = nil
+			for _, doc := range docs {
+				if hasNodeKey(doc.Content[0], keys) {
+					targets = append(targets, doc)
+				}
+			}
+			if len(targets) == 0 {
+				return nil, fmt.Errorf("cannot set %s: none of the %d documents has the key", e.Key, len(docs))
+			}
+		}
+		for _, doc := range targets {
+			if err := setNodeKey(doc.Content[0], keys, &value); err != nil {
+				return nil, fmt.Errorf("cannot set %s: %w", e.Key, err)
+			}
+		}
+
+		if format == formatJSON {
+			if len(docs) > 1 {
+				return nil, fmt.Errorf("expected a single JSON value, got %d", len(docs))
+			}
+			return encodeJSONNode(docs[0].Content[0], detectIndent(content), bytes.HasSuffix(content, []byte("\n")))
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(detectIndentWidth(content))
+		for _, doc := range docs {
+			if err := enc.Encode(doc); err != nil {
+				return nil, err
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// hasNodeKey reports whether the path keys exists below node
+func hasNodeKey(node *yaml.Node, keys []string) bool {
+	for _, key := range keys {
+		var child *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					child = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(node.Content) {
+				child = node.Content[index]
+			}
+		}
+		if child == nil {
+			return false
+		}
+		node = child
+	}
+	return true
+}
+
+// setNodeKey sets the value at the path keys below node, creating missing mappings
+func setNodeKey(node *yaml.Node, keys []string, value *yaml.Node) error {
+	key, rest := keys[0], keys[1:]
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				if len(rest) == 0 {
+					node.Content[i+1] = value
+					return nil
+				}
+				return setNodeKey(node.Content[i+1], rest, value)
+			}
+		}
+		child := value
+		if len(rest) > 0 {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			if err := setNodeKey(child, rest, value); err != nil {
+				return err
+			}
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		return nil
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(node.Content) {
+			return fmt.Errorf("%q is not an index of a list of %d items", key, len(node.Content))
+		}
+		if len(rest) == 0 {
+			node.Content[index] = value
+			return nil
+		}
+		return setNodeKey(node.Content[index], rest, value)
+	}
+	return fmt.Errorf("%q is not inside a mapping or a list", key)
+}
+
+// detectIndent returns the indentation of the first indented line of content, defaulting to two spaces
+func detectIndent(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+func detectIndentWidth(content []byte) int {
+	if indent := detectIndent(content); !strings.Contains(indent, "\t") && len(indent) >= 2 {
+		return len(indent)
+	}
+	return 2
+}
+
+// encodeJSONNode writes a YAML node parsed from JSON back as indented JSON
+func encodeJSONNode(node *yaml.Node, indent string, trailingNewline bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSONNode(&buf, node, indent, 0); err != nil {
+		return nil, err
+	}
+	if trailingNewline {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSONNode(buf *bytes.Buffer, node *yaml.Node, indent string, level int) error {
+	writeString := func(s string) {
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(s)
+		buf.Truncate(buf.Len() - 1) // Encode adds a newline
+	}
+	open, close := "{", "}"
+	if node.Kind == yaml.SequenceNode {
+		open, close = "[", "]"
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString(open + close)
+			return nil
+		}
+		buf.WriteString(open + "\n")
+		step := 1
+		if node.Kind == yaml.MappingNode {
+			step = 2
+		}
+		for i := 0; i < len(node.Content); i += step {
+			buf.WriteString(strings.Repeat(indent, level+1))
+			value := node.Content[i]
+			if node.Kind == yaml.MappingNode {
+				writeString(node.Content[i].Value)
+				buf.WriteString(": ")
+				value = node.Content[i+1]
+			}
+			if err := writeJSONNode(buf, value, indent, level+1); err != nil {
+				return err
+			}
+			if i+step < len(node.Content) {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(strings.Repeat(indent, level) + close)
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!int", "!!float", "!!bool":
+			buf.WriteString(node.Value)
+		case "!!null":
+			buf.WriteString("null")
+		default:
+			writeString(node.Value)
+		}
+	default:
+		return fmt.Errorf("cannot write YAML node of kind %d as JSON", node.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files with the given contents below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.yaml", "app.yaml", true},
+		{"*.yaml", "deploy/app.yaml", false},
+		{"**/*.yaml", "app.yaml", true},
+		{"**/*.yaml", "deploy/prod/app.yaml", true},
+		{"deploy/**", "deploy/prod/app.yaml", true},
+		{"deploy/**/app.yaml", "deploy/app.yaml", true},
+		{"deploy/*/app.yaml", "deploy/app.yaml", false},
+		{"**/go.mod", "tools/go.mod", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, expected %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"deploy/app.yaml": "# Service\nspec:\n  image: app:1.0 # pinned\n  replicas: 1\n",
+		"deploy/job.yaml": "image: job:1.0\n",
+		"README.md":       "image: app:1.0\n",
+		"package.json":    "{\n    \"name\": \"web\",\n    \"version\": \"1.0.0\",\n    \"scripts\": {\"test\": \"jest\"}\n}\n",
+		".gitignore":      "/tmp\n/dist\n",
+		"go.mod":          "module example.com/app\n\ngo 1.21\n\nrequire example.com/lib v1.0.0\n\nrequire (\n\texample.com/other v0.1.0\n\texample.com/lib/v2 v2.0.0 // indirect\n)\n",
+		"tools/go.mod":    "module example.com/tools\n\nrequire (\n\texample.com/lib v1.0.0\n)\n",
+		".git/config":     "image: app:1.0\n",
+	})
+
+	edits := []fileEdit{
+		{Replace: &replaceEdit{Files: "deploy/**/*.yaml", Pattern: `image: (\w+):1\.0`, With: "image: ${1}:1.1"}},
+		{Set: &setEdit{File: "deploy/app.yaml", Key: "spec.replicas", Value: 3}},
+		{Set: &setEdit{File: "deploy/app.yaml", Key: "spec.resources.cpu", Value: "500m"}},
+		{Set: &setEdit{File: "package.json", Key: "version", Value: "1.1.0"}},
+		{Set: &setEdit{File: "package.json", Key: "private", Value: true}},
+		{AddLine: &addLineEdit{File: ".gitignore", Line: "/build", After: `^/tmp$`}},
+		{AddLine: &addLineEdit{File: ".gitignore", Line: "/dist"}},
+		{AddLine: &addLineEdit{File: "new/.keep", Line: "keep"}},
+		{RemoveLine: &removeLineEdit{File: ".gitignore", Line: "/tmp"}},
+		{RemoveLine: &removeLineEdit{File: "missing.txt", Pattern: "x"}},
+		{GoModule: &goModuleEdit{Module: "example.com/lib", Version: "v1.2.0"}},
+	}
+	if err := validateEdits(edits); err != nil {
+		t.Fatalf("validateEdits failed: %v", err)
+	}
+	if err := applyEdits(dir, edits); err != nil {
+		t.Fatalf("applyEdits failed: %v", err)
+	}
+
+	want := map[string]string{
+		"deploy/app.yaml": "# Service\nspec:\n  image: app:1.1 # pinned\n  replicas: 3\n  resources:\n    cpu: 500m\n",
+		"deploy/job.yaml": "image: job:1.1\n",
+		"README.md":       "image: app:1.0\n",
+		"package.json":    "{\n    \"name\": \"web\",\n    \"version\": \"1.1.0\",\n    \"scripts\": {\n        \"test\": \"jest\"\n    },\n    \"private\": true\n}\n",
+		".gitignore":      "/build\n/dist\n",
+		"new/.keep":       "keep\n",
+		"go.mod":          "module example.com/app\n\ngo 1.21\n\nrequire example.com/lib v1.2.0\n\nrequire (\n\texample.com/other v0.1.0\n\texample.com/lib/v2 v2.0.0 // indirect\n)\n",
+		"tools/go.mod":    "module example.com/tools\n\nrequire (\n\texample.com/lib v1.2.0\n)\n",
+		".git/config":     "image: app:1.0\n",
+	}
+	for name, content := range want {
+		if got := readFile(t, dir, name); got != content {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, content, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected remove_line to leave missing files alone, got %v", err)
+	}
+}
+
+func TestApplyEditsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"values.yaml": "replicas: 1\nports: [80]\n"})
+	for _, edit := range []fileEdit{
+		{Set: &setEdit{File: "values.yaml", Key: "replicas.min", Value: 1}},
+		{Set: &setEdit{File: "values.yaml", Key: "ports.3", Value: 443}},
+		{AddLine: &addLineEdit{File: "../outside", Line: "x"}},
+	} {
+		if err := applyEdits(dir, []fileEdit{edit}); err == nil {
+			t.Errorf("Expected an error for %+v", edit)
+		}
+	}
+
+	for _, edit := range []fileEdit{
+		{},
+		{Replace: &replaceEdit{Files: "*.go", Pattern: "a"}, AddLine: &addLineEdit{File: "a", Line: "b"}},
+		{Replace: &replaceEdit{Files: "[", Pattern: "a"}},
+		{Replace: &replaceEdit{Files: "*.go", Pattern: "("}},
+		{Set: &setEdit{File: "values.toml", Key: "a"}},
+		{RemoveLine: &removeLineEdit{File: "a", Line: "x", Pattern: "x"}},
+		{GoModule: &goModuleEdit{Module: "example.com/lib"}},
+	} {
+		if err := edit.validate(); err == nil {
+			t.Errorf("Expected a validation error for %+v", edit)
+		}
+	}
+}
+
+func TestSetEditMultipleDocuments(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"deploy.yaml": "kind: Service\nspec:\n  port: 80\n---\nkind: Deployment\nspec:\n  replicas: 1\n"})
+
+	if err := applyEdits(dir, []fileEdit{{Set: &setEdit{File: "deploy.yaml", Key: "spec.replicas", Value: 3}}}); err != nil {
+		t.Fatalf("applyEdits failed: %v", err)
+	}
+	want := "kind: Service\nspec:\n  port: 80\n---\nkind: Deployment\nspec:\n  replicas: 3\n"
+	if got := readFile(t, dir, "deploy.yaml"); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	if err := applyEdits(dir, []fileEdit{{Set: &setEdit{File: "deploy.yaml", Key: "metadata.name", Value: "app"}}}); err == nil {
+		t.Error("Expected an error for a key none of the documents has")
+	}
+	if got := readFile(t, dir, "deploy.yaml"); got != want {
+		t.Errorf("Expected the file to be left unchanged, got\n%s", got)
+	}
+}
+
+func TestEditsRefuseSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	writeFiles(t, outside, map[string]string{"target.txt": "outside\n", "values.yaml": "a: 1\n"})
+	dir := t.TempDir()
+	if err := os.Symlink(filepath.Join(outside, "target.txt"), filepath.Join(dir, "x.txt")); err != nil {
+		t.Skipf("Cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, edit := range []fileEdit{
+		{AddLine: &addLineEdit{File: "x.txt", Line: "injected"}},
+		{RemoveLine: &removeLineEdit{File: "x.txt", Line: "outside"}},
+		{AddLine: &addLineEdit{File: "linked/new.txt", Line: "injected"}},
+		{Set: &setEdit{File: "linked/values.yaml", Key: "a", Value: 2}},
+	} {
+		if err := applyEdits(dir, []fileEdit{edit}); err == nil || !strings.Contains(err.Error(), "symbolic link") {
+			t.Errorf("Expected a symbolic link error for %+v, got %v", edit, err)
+		}
+	}
+	if got := readFile(t, outside, "target.txt"); got != "outside\n" {
+		t.Errorf("Expected the file outside of the repository to be left unchanged, got %q", got)
+	}
+	if got := readFile(t, outside, "values.yaml"); got != "a: 1\n" {
+		t.Errorf("Expected the file outside of the repository to be left unchanged, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be created outside of the repository, got %v", err)
+	}
+}
+
+func TestDryRunShowsEditDiff(t *testing.T) {
+	origin := newOriginRepo(t)
+	originalMockCloneURL := mockCloneURL
+	defer func() { mockCloneURL = originalMockCloneURL }()
+	mockCloneURL = func(details Repo) string { return "file://" + origin }
+	be := useMemoryBackend(t)
+
+	config := &Config{Repos: map[string]Repo{
+		"go":   {Repo: "org/a", Base: "main", Head: "go-1.22", Title: "Bump Go", Edits: []fileEdit{{Replace: &replaceEdit{Files: "go.mod", Pattern: `go 1\.21`, With: "go 1.22"}}}},
+		"none": {Repo: "org/a", Base: "main", Head: "none", Title: "Nothing", Edits: []fileEdit{{RemoveLine: &removeLineEdit{File: "go.mod", Line: "absent"}}}},
+	}}
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, true) })
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	for _, want := range []string{"DRY RUN: Edits for go:\n", "--- a/go.mod\n+++ b/go.mod\n", "-go 1.21\n+go 1.22\n", "DRY RUN: Edits change nothing for none"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, output)
+		}
+	}
+	if len(be.calls) != 0 {
+		t.Errorf("Expected nothing to be created, got %v", be.calls)
+	}
+}
+
+func TestRenderEdits(t *testing.T) {
+	edits := []fileEdit{
+		{GoModule: &goModuleEdit{Module: "example.com/lib", Version: "{{ .Vars.version }}"}},
+		{Set: &setEdit{File: "a.yaml", Key: "name", Value: "{{ .Name }}"}},
+	}
+	rendered, err := renderEdits(edits, newTemplateData("a", Repo{Repo: "org/api", Vars: map[string]string{"version": "v1.2.0"}}))
+	if err != nil {
+		t.Fatalf("renderEdits failed: %v", err)
+	}
+	if rendered[0].GoModule.Version != "v1.2.0" || rendered[1].Set.Value != "api" {
+		t.Errorf("Unexpected rendered edits: %+v, %+v", rendered[0].GoModule, rendered[1].Set)
+	}
+	if edits[0].GoModule.Version != "{{ .Vars.version }}" {
+		t.Error("Expected the original edits to be left unchanged")
+	}
+	if _, err := renderEdits(edits, newTemplateData("a", Repo{Repo: "org/api"})); err == nil {
+		t.Error("Expected an error for a missing variable")
+	}
+}
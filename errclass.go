@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errorClass is a coarse category of a failed forge operation
+type errorClass string
+
+const (
+	classRateLimited   errorClass = "rate_limited"
+	classAuth          errorClass = "auth"
+	classNotFound      errorClass = "not_found"
+	classValidation    errorClass = "validation"
+	classAlreadyExists errorClass = "already_exists"
+	classNetwork       errorClass = "network"
+	classTimeout       errorClass = "timeout"
+	classUnknown       errorClass = "unknown"
+)
+
+// transient reports whether an operation failing with this class is worth retrying
+func (c errorClass) transient() bool {
+	return c == classRateLimited || c == classNetwork || c == classTimeout
+}
+
+// classPatterns maps lowercase substrings of gh error output to a class.
+// Order matters: the first match wins, so more specific patterns come first.
+var classPatterns = []struct {
+	class    errorClass
+	patterns []string
+}{
+	{classRateLimited, []string{"rate limit", "submitted too quickly", "abuse detection", "http 429"}},
+	{classAlreadyExists, []string{"already exists"}},
+	{classAuth, []string{"http 401", "bad credentials", "gh auth login", "authentication", "http 403", "resource not accessible", "permission"}},
+	{classNotFound, []string{"http 404", "could not resolve to a repository", "not found"}},
+	{classValidation, []string{"http 422", "validation failed", "no commits between", "invalid"}},
+	{classNetwork, []string{"connection refused", "connection reset", "no such host", "i/o timeout", "tls handshake", "unexpected eof", "http 500", "http 502", "http 503", "http 504"}},
+}
+
+// classifyOutput classifies an error from the text a command or API reported
+func classifyOutput(output string) errorClass {
+	lower := strings.ToLower(output)
+	for _, entry := range classPatterns {
+		for _, pattern := range entry.patterns {
+			if strings.Contains(lower, pattern) {
+				return entry.class
+			}
+		}
+	}
+	return classUnknown
+}
+
+// commandError is returned when a command fails; it keeps the captured stderr and its class
+type commandError struct {
+	Args   []string
+	Stderr string
+	Class  errorClass
+	Err    error
+}
+
+func (e *commandError) Error() string {
+	msg := fmt.Sprintf("Error running command %s: %v", e.Args, e.Err)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *commandError) Unwrap() error {
+	return e.Err
+}
+
+// newCommandError builds a classified commandError
+func newCommandError(args []string, stderr string, err error) *commandError {
+	class := classifyOutput(stderr)
+	if errors.Is(err, context.DeadlineExceeded) {
+		class = classTimeout
+	}
+	return &commandError{Args: args, Stderr: stderr, Class: class, Err: err}
+}
+
+// classOf returns the class of err, or classUnknown if it was never classified
+func classOf(err error) errorClass {
+	var classified interface{ errorClass() errorClass }
+	if errors.As(err, &classified) {
+		return classified.errorClass()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return classTimeout
+	}
+	return classUnknown
+}
+
+func (e *commandError) errorClass() errorClass {
+	return e.Class
+}
+
+// classifiedError attaches a class to an error that did not come from a command
+type classifiedError struct {
+	class errorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) errorClass() errorClass {
+	return e.class
+}
+
+// withClass returns err annotated with class
+func withClass(class errorClass, err error) error {
+	return &classifiedError{class: class, err: err}
+}
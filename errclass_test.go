@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		want   errorClass
+	}{
+		{"HTTP 403: API rate limit exceeded for user ID 1", classRateLimited},
+		{"You have exceeded a secondary rate limit", classRateLimited},
+		{`a pull request for branch "dev" into branch "main" already exists:`, classAlreadyExists},
+		{"HTTP 401: Bad credentials", classAuth},
+		{"To get started with GitHub CLI, please run:  gh auth login", classAuth},
+		{"GraphQL: Could not resolve to a Repository with the name 'org/nope'.", classNotFound},
+		{"pull request create failed: GraphQL: No commits between main and dev", classValidation},
+		{"HTTP 422: Validation Failed", classValidation},
+		{"dial tcp: lookup api.github.com: no such host", classNetwork},
+		{"HTTP 502: Bad Gateway", classNetwork},
+		{"something odd happened", classUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := classifyOutput(tt.output); got != tt.want {
+			t.Errorf("classifyOutput(%q) = %s, want %s", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestClassOf(t *testing.T) {
+	cmdErr := newCommandError([]string{"gh", "pr", "create"}, "HTTP 404: Not Found", errors.New("exit status 1"))
+	wrapped := fmt.Errorf("failed to create PR for r: %w", cmdErr)
+	if got := classOf(wrapped); got != classNotFound {
+		t.Errorf("Expected not_found through wrapping, got %s", got)
+	}
+
+	timeout := newCommandError([]string{"gh"}, "", context.DeadlineExceeded)
+	if got := classOf(timeout); got != classTimeout {
+		t.Errorf("Expected timeout, got %s", got)
+	}
+
+	if got := classOf(errors.New("plain")); got != classUnknown {
+		t.Errorf("Expected unknown for unclassified errors, got %s", got)
+	}
+}
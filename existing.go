@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+// Policies for handling an open pull request that already exists for the same repo, base and head
+const (
+	existingFail   = "fail"
+	existingSkip   = "skip"
+	existingUpdate = "update"
+)
+
+// validateExistingPolicy checks the value of `on_existing` / --on-existing
+func validateExistingPolicy(policy string) error {
+	switch policy {
+	case "", existingFail, existingSkip, existingUpdate:
+		return nil
+	}
+	return fmt.Errorf("invalid existing PR policy %q, expected %q, %q or %q", policy, existingSkip, existingUpdate, existingFail)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockExistingPR makes `gh pr list` report an open PR for every repo in open,
+// and records every other command that is run.
+func mockExistingPR(t *testing.T, open map[string]bool) *[][]string {
+	t.Helper()
+	originalMockRunCommand := mockRunCommand
+	originalMockRunCommandOutput := mockRunCommandOutput
+	t.Cleanup(func() {
+		mockRunCommand = originalMockRunCommand
+		mockRunCommandOutput = originalMockRunCommandOutput
+	})
+
+	var mu sync.Mutex
+	var calls [][]string
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		if args[1] == "pr" && args[2] == "list" {
+			for i, arg := range args {
+				if arg == "--repo" && open[args[i+1]] {
+					return []byte(`[{"number":42,"url":"https://github.com/` + args[i+1] + `/pull/42"}]`), nil
+				}
+			}
+			return []byte(`[]`), nil
+		}
+		return nil, mockRunCommand(args...)
+	}
+	mockRunCommand = func(args ...string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, append([]string(nil), args...))
+		return nil
+	}
+	return &calls
+}
+
+func TestCreatePullRequestExistingPolicies(t *testing.T) {
+	newConfig := func(policy string) *Config {
+		return &Config{
+			Preflight:  new(bool),
+			OnExisting: policy,
+			Repos: map[string]Repo{
+				"existing": {Repo: "org/existing", Base: "main", Head: "dev", Title: "New title", Body: "B", Labels: []string{"l1"}},
+				"fresh":    {Repo: "org/fresh", Base: "main", Head: "dev", Title: "T", Body: "B"},
+			},
+		}
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		calls := mockExistingPR(t, map[string]bool{"org/existing": true})
+		if err := createPullRequest(newConfig(existingSkip), false); err != nil {
+			t.Fatalf("Expected nil error, got %v", err)
+		}
+		if len(*calls) != 1 || !strings.Contains(strings.Join((*calls)[0], " "), "pr create") || !strings.Contains(strings.Join((*calls)[0], " "), "org/fresh") {
+			t.Errorf("Expected only fresh repo to be created, got %v", *calls)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		calls := mockExistingPR(t, map[string]bool{"org/existing": true})
+		if err := createPullRequest(newConfig(existingUpdate), false); err != nil {
+			t.Fatalf("Expected nil error, got %v", err)
+		}
+		var edit string
+		for _, call := range *calls {
+			if call[2] == "edit" {
+				edit = strings.Join(call, " ")
+			}
+		}
+		if edit != "gh pr edit 42 --repo org/existing --title New title --body B --add-label l1" {
+			t.Errorf("Unexpected edit command: %q", edit)
+		}
+	})
+
+	t.Run("FailByDefault", func(t *testing.T) {
+		calls := mockExistingPR(t, map[string]bool{"org/existing": true})
+		err := createPullRequest(newConfig(""), false)
+		if err == nil || !strings.Contains(err.Error(), "PR already exists for existing") {
+			t.Fatalf("Expected already exists error, got %v", err)
+		}
+		for _, call := range *calls {
+			if strings.Contains(strings.Join(call, " "), "org/existing") {
+				t.Errorf("Expected no command for existing repo, got %v", call)
+			}
+		}
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		mockExistingPR(t, nil)
+		err := createPullRequest(newConfig("ignore"), false)
+		if err == nil || !strings.Contains(err.Error(), "invalid existing PR policy") {
+			t.Errorf("Expected invalid policy error, got %v", err)
+		}
+	})
+}
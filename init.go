@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// initOptions are the settings of the init command
+type initOptions struct {
+	selector repoSelector
+	provider string
+	host     string
+	backend  string
+	defaults Repo // base, head, title and body for the generated defaults
+}
+
+// generatedEntry is an entry of a generated configuration
+type generatedEntry struct {
+	Repo string `yaml:"repo"`
+	Base string `yaml:"base,omitempty"`
+}
+
+// generatedConfig is the starter configuration written by the init command
+type generatedConfig struct {
+	Defaults struct {
+		Base  string `yaml:"base,omitempty"`
+		Head  string `yaml:"head,omitempty"`
+		Title string `yaml:"title,omitempty"`
+		Body  string `yaml:"body,omitempty"`
+		// Provider and Host are only set for other forges than github.com
+		Provider string `yaml:"provider,omitempty"`
+		Host     string `yaml:"host,omitempty"`
+	} `yaml:"defaults"`
+	Repos map[string]generatedEntry `yaml:"repos"`
+}
+
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: gh bulkpr init --owner <owner> [flags]\nWrite a starter configuration with one entry per repository of an owner.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	var opts initOptions
+	var topics stringList
+	fs.StringVar(&opts.selector.Owner, "owner", "", "Organization or user whose repositories are listed (required)")
+	fs.Var(&topics, "topic", "Only include repositories with this topic (repeatable, all must match)")
+	fs.StringVar(&opts.selector.Language, "language", "", "Only include repositories with this primary language")
+	fs.StringVar(&opts.selector.Name, "name", "", "Only include repositories whose name matches this regular expression")
+	fs.StringVar(&opts.selector.Visibility, "visibility", "", "Only include public, private or internal repositories")
+	fs.BoolVar(&opts.selector.Archived, "archived", false, "Include archived repositories")
+	fs.BoolVar(&opts.selector.Forks, "forks", false, "Include forks")
+	fs.StringVar(&opts.defaults.Base, "base", "", "Base branch for the defaults (default: each repository's default branch)")
+	fs.StringVar(&opts.defaults.Head, "head", "", "Head branch for the defaults")
+	fs.StringVar(&opts.defaults.Title, "title", "", "PR title for the defaults")
+	fs.StringVar(&opts.defaults.Body, "body", "", "PR body for the defaults, as text or the path of a file")
+	fs.StringVar(&opts.provider, "provider", "", "Forge hosting the repositories (default github)")
+	fs.StringVar(&opts.host, "host", "", "Host of a self-hosted forge")
+	fs.StringVar(&opts.backend, "backend", "", "How to reach GitHub: gh or api (default gh)")
+	out := fs.String("out", "", "File to write the configuration to (default: standard output)")
+	force := fs.Bool("force", false, "Overwrite the file given with --out if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts.selector.Topics = topics
+
+	if err := validateBackend(opts.backend); err != nil {
+		return err
+	}
+	if *out == "" {
+		return generateConfig(os.Stdout, opts)
+	}
+
+	// Fail before listing the repositories if the file cannot be written,
+	// but only create it once the configuration was generated
+	if _, err := os.Stat(*out); err == nil && !*force {
+		return fmt.Errorf("failed to create %s: %w", *out, os.ErrExist)
+	}
+	var buf bytes.Buffer
+	if err := generateConfig(&buf, opts); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(*out, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", *out, err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", *out, err)
+	}
+	return file.Close()
+}
+
+// generateConfig lists the repositories selected by opts and writes a configuration
+// with one entry per repository to w
+func generateConfig(w io.Writer, opts initOptions) error {
+	if err := opts.selector.validate(); err != nil {
+		return err
+	}
+	target := Repo{Provider: opts.provider, Host: opts.host}
+	lister, err := backendWith[repoLister](&Config{Backend: opts.backend}, target, "listing repositories")
+	if err != nil {
+		return err
+	}
+
+	var repos []repository
+	err = newRetrier(&Config{}).do(context.Background(), "Listing repositories of "+opts.selector.Owner, func(ctx context.Context) (err error) {
+		repos, err = selectRepositories(ctx, lister, opts.selector)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		return fmt.Errorf("no repositories of %s match the filters", opts.selector.Owner)
+	}
+
+	var config generatedConfig
+	config.Defaults.Base = opts.defaults.Base
+	config.Defaults.Head = opts.defaults.Head
+	config.Defaults.Title = opts.defaults.Title
+	config.Defaults.Body = opts.defaults.Body
+	config.Defaults.Provider = opts.provider
+	config.Defaults.Host = opts.host
+	config.Repos = make(map[string]generatedEntry, len(repos))
+	for _, r := range repos {
+		entry := generatedEntry{Repo: r.FullName}
+		if opts.defaults.Base == "" {
+			entry.Base = r.DefaultBranch
+		}
+		config.Repos[repoKey(r.FullName)] = entry
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# Generated by `bulkpr init` for %s: %d repositories\n", opts.selector.Owner, len(repos))
+	_, err = w.Write(data)
+	return err
+}
+
+// repoKey returns the key under `repos:` for a repository: its name, lower-cased
+func repoKey(fullName string) string {
+	_, name, _ := strings.Cut(fullName, "/")
+	return strings.ToLower(name)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateConfig(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.repos = []repository{
+		{FullName: "org/Billing", Topics: []string{"service"}, DefaultBranch: "main"},
+		{FullName: "org/payments", Topics: []string{"service"}, DefaultBranch: "master"},
+		{FullName: "org/website", DefaultBranch: "main"},
+		{FullName: "org/legacy", Topics: []string{"service"}, Archived: true},
+		{FullName: "other/service", Topics: []string{"service"}},
+	}
+
+	var out strings.Builder
+	opts := initOptions{selector: repoSelector{Owner: "org", Topics: []string{"service"}}, defaults: Repo{Head: "chore/update", Title: "Update"}}
+	if err := generateConfig(&out, opts); err != nil {
+		t.Fatalf("generateConfig failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "# Generated by `bulkpr init` for org: 2 repositories\n") {
+		t.Errorf("Unexpected header: %q", out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "campaign.yaml")
+	if err := os.WriteFile(path, []byte(out.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readYAMLConfig([]string{path})
+	if err != nil {
+		t.Fatalf("Generated config does not parse: %v\n%s", err, out.String())
+	}
+	if len(config.Repos) != 2 || config.Defaults.Head != "chore/update" || config.Defaults.Title != "Update" {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if got := config.Repos["billing"]; got.Repo != "org/Billing" || got.Base != "main" {
+		t.Errorf("Unexpected entry for billing: %+v", got)
+	}
+	if got := config.Repos["payments"]; got.Base != "master" {
+		t.Errorf("Expected the default branch as base, got %+v", got)
+	}
+
+	// A fixed base replaces the default branches
+	out.Reset()
+	opts.defaults.Base = "develop"
+	if err := generateConfig(&out, opts); err != nil {
+		t.Fatalf("generateConfig failed: %v", err)
+	}
+	if strings.Contains(out.String(), "master") || !strings.Contains(out.String(), "base: develop") {
+		t.Errorf("Expected only the fixed base, got:\n%s", out.String())
+	}
+
+	opts.selector.Topics = []string{"frontend"}
+	if err := generateConfig(&out, opts); err == nil || !strings.Contains(err.Error(), "no repositories") {
+		t.Errorf("Expected an error without matching repositories, got %v", err)
+	}
+}
+
+func TestRunInitWritesFileOnlyOnSuccess(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.repos = []repository{{FullName: "org/api", DefaultBranch: "main"}}
+	out := filepath.Join(t.TempDir(), "campaign.yaml")
+
+	be.errors = map[string]error{"list org": withClass(classAuth, errors.New("bad credentials"))}
+	if err := runInit([]string{"--owner", "org", "--out", out}); err == nil {
+		t.Fatal("Expected the listing error")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("Expected no file after a failed listing, got %v", err)
+	}
+
+	be.errors = nil
+	if err := runInit([]string{"--owner", "org", "--out", out}); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if content, _ := os.ReadFile(out); !strings.Contains(string(content), "repo: org/api") {
+		t.Errorf("Unexpected configuration:\n%s", content)
+	}
+	if err := runInit([]string{"--owner", "org", "--out", out}); err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Errorf("Expected an existing file to be kept without --force, got %v", err)
+	}
+	if err := runInit([]string{"--owner", "org", "--out", out, "--force"}); err != nil {
+		t.Errorf("Expected --force to replace the file, got %v", err)
+	}
+}
+
+func TestGenerateConfigUnsupportedProvider(t *testing.T) {
+	err := generateConfig(&strings.Builder{}, initOptions{selector: repoSelector{Owner: "org"}, provider: providerGitLab})
+	if classOf(err) != classValidation {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+}
+
+func TestGitHubBackendListRepositories(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+"?page="+r.URL.Query().Get("page"))
+		switch {
+		case r.URL.Path == "/users/someone/repos" && r.URL.Query().Get("page") == "1":
+			var repos []string
+			for i := 0; i < 100; i++ {
+				repos = append(repos, fmt.Sprintf(`{"full_name": "someone/r%d", "default_branch": "main"}`, i))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(repos, ","))
+		case r.URL.Path == "/users/someone/repos":
+			fmt.Fprint(w, `[{"full_name": "someone/last", "topics": ["go"], "archived": true, "fork": true, "visibility": "public"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repos, err := newGitHubBackend(server.URL, "").listRepositories(context.Background(), "someone")
+	if err != nil {
+		t.Fatalf("listRepositories failed: %v", err)
+	}
+	if len(repos) != 101 {
+		t.Fatalf("Expected 101 repositories, got %d (requests: %v)", len(repos), paths)
+	}
+	last := repos[100]
+	if last.FullName != "someone/last" || !last.Archived || !last.Fork || last.Visibility != "public" || last.Topics[0] != "go" {
+		t.Errorf("Unexpected repository: %+v", last)
+	}
+	if want := "[/orgs/someone/repos?page=1 /user?page= /users/someone/repos?page=1 /users/someone/repos?page=2]"; fmt.Sprint(paths) != want {
+		t.Errorf("Unexpected requests: %v", paths)
+	}
+}
+
+func TestGitHubBackendListRepositoriesOfAuthenticatedUser(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login": "Me"}`)
+		case "/user/repos":
+			if r.URL.Query().Get("affiliation") != "owner" {
+				t.Errorf("Expected only owned repositories, got %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"full_name": "me/public", "visibility": "public"}, {"full_name": "me/secret", "visibility": "private"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repos, err := newGitHubBackend(server.URL, "").listRepositories(context.Background(), "me")
+	if err != nil {
+		t.Fatalf("listRepositories failed: %v", err)
+	}
+	if len(repos) != 2 || repos[1].Visibility != "private" {
+		t.Errorf("Expected the private repositories of the authenticated user, got %+v (requests: %v)", repos, paths)
+	}
+}
+
+func TestGHBackendListRepositories(t *testing.T) {
+	originalMockRunCommandOutput := mockRunCommandOutput
+	defer func() { mockRunCommandOutput = originalMockRunCommandOutput }()
+	var got []string
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		got = args
+		return []byte(`[{"nameWithOwner": "org/api", "repositoryTopics": [{"name": "go"}], "primaryLanguage": {"name": "Go"},
+			"visibility": "PRIVATE", "isArchived": false, "isFork": false, "defaultBranchRef": {"name": "main"}}]`), nil
+	}
+
+	repos, err := ghBackend{}.listRepositories(context.Background(), "org")
+	if err != nil || len(repos) != 1 {
+		t.Fatalf("Unexpected result: %v, %v", repos, err)
+	}
+	if r := repos[0]; r.FullName != "org/api" || r.Language != "Go" || r.Visibility != "private" || r.DefaultBranch != "main" || r.Topics[0] != "go" {
+		t.Errorf("Unexpected repository: %+v", r)
+	}
+	if strings.Join(got[:4], " ") != "gh repo list org" {
+		t.Errorf("Unexpected gh command: %v", got)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configIssue is a problem found in a configuration file, at a line when it is known
+type configIssue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (i configIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.File, i.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// Patterns of valid `owner/name` repositories; GitLab allows nested groups
+var (
+	repoNamePattern       = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+	gitlabRepoNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)+$`)
+)
+
+// missingFields returns the required fields an entry does not set, after the defaults were applied
+func missingFields(details Repo) []string {
+	var missing []string
+	if details.Repo == "" {
+		missing = append(missing, "repo")
+	}
+	if details.Base == "" {
+		missing = append(missing, "base")
+	}
+	if details.Head == "" {
+		missing = append(missing, "head")
+	}
+	if strings.TrimSpace(details.Title) == "" {
+		missing = append(missing, "title")
+	}
+	return missing
+}
+
+// entryLocation is where an entry under `repos:` is defined, and the lines of its fields
+type entryLocation struct {
+	file   string
+	line   int
+	fields map[string]int
+}
+
+// configLinter collects the issues of a set of configuration files
+type configLinter struct {
+	issues   []configIssue
+	entries  map[string]entryLocation // By key; later files win like in readYAMLConfig
+	defaults map[string]bool          // Fields set in any `defaults:` block
+}
+
+// lintConfigFiles checks configuration files without contacting any forge. It reports unknown fields,
+// missing required fields, malformed repositories, empty titles and entries sharing a repository and head,
+// with the file and line of each problem. An error is only returned when a file cannot be read.
+func lintConfigFiles(filenames []string) ([]configIssue, error) {
+	l := &configLinter{entries: map[string]entryLocation{}, defaults: map[string]bool{}}
+	roots := map[string]*yaml.Node{}
+	decoded := true
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			l.issues = append(l.issues, configIssue{File: filename, Message: err.Error()})
+			decoded = false
+			continue
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := resolveAlias(doc.Content[0])
+		roots[filename] = root
+		l.checkFields(filename, root, reflect.TypeOf(Config{}), "")
+		l.collect(filename, root)
+		if err := root.Decode(&Config{}); err != nil {
+			l.addDecodeIssues(filename, err)
+			decoded = false
+		}
+	}
+	if !decoded {
+		// Entries cannot be checked reliably in files that do not decode
+		return l.sorted(), nil
+	}
+
+	config, err := readYAMLConfig(filenames)
+	if err != nil {
+		l.addConfigIssue(filenames, roots, err)
+		return l.sorted(), nil
+	}
+	l.checkEntries(config)
+	return l.sorted(), nil
+}
+
+// yamlErrorLine matches the line prefix of the messages of a yaml.TypeError
+var yamlErrorLine = regexp.MustCompile(`^line (\d+): `)
+
+// addDecodeIssues reports the values of a file that do not fit the configuration, at their lines
+func (l *configLinter) addDecodeIssues(file string, err error) {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		l.issues = append(l.issues, configIssue{File: file, Message: err.Error()})
+		return
+	}
+	for _, message := range typeErr.Errors {
+		issue := configIssue{File: file, Message: message}
+		if m := yamlErrorLine.FindStringSubmatch(message); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			issue.Message = strings.TrimPrefix(message, m[0])
+		}
+		l.issues = append(l.issues, issue)
+	}
+}
+
+// addConfigIssue reports an error of readYAMLConfig at the file it comes from, which is the first file
+// that makes reading fail when the files are read one more at a time. An error of the whole set,
+// like having no entries at all, is reported for all files.
+func (l *configLinter) addConfigIssue(filenames []string, roots map[string]*yaml.Node, err error) {
+	for i, filename := range filenames {
+		if _, prefixErr := readYAMLConfig(filenames[:i+1]); prefixErr != nil && !errors.Is(prefixErr, errNoRepositories) {
+			issue := configIssue{File: filename, Message: prefixErr.Error()}
+			if defaults := mappingValue(roots[filename], "defaults"); defaults != nil {
+				issue.Line = defaults.Line
+			}
+			l.issues = append(l.issues, issue)
+			return
+		}
+	}
+	l.issues = append(l.issues, configIssue{File: strings.Join(filenames, ", "), Message: err.Error()})
+}
+
+func (l *configLinter) sorted() []configIssue {
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].File != l.issues[j].File {
+			return l.issues[i].File < l.issues[j].File
+		}
+		return l.issues[i].Line < l.issues[j].Line
+	})
+	return l.issues
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// yamlFields maps the YAML keys of a struct type to their field types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// checkFields reports the keys of node that t does not have, recursing into nested structs, lists and maps
+func (l *configLinter) checkFields(file string, node *yaml.Node, t reflect.Type, path string) {
+	node = resolveAlias(node)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				where := "at the top level"
+				if path != "" {
+					where = "in " + path
+				}
+				l.issues = append(l.issues, configIssue{File: file, Line: key.Line, Message: fmt.Sprintf("unknown field %q %s", key.Value, where)})
+				continue
+			}
+			l.checkFields(file, value, fieldType, joinPath(path, key.Value))
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			l.checkFields(file, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			l.checkFields(file, node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return resolveAlias(node.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// collect records where the entries and default fields of a file are defined
+func (l *configLinter) collect(file string, root *yaml.Node) {
+	if defaults := mappingValue(root, "defaults"); defaults != nil && defaults.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(defaults.Content); i += 2 {
+			l.defaults[defaults.Content[i].Value] = true
+		}
+	}
+	repos := mappingValue(root, "repos")
+	if repos == nil || repos.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(repos.Content); i += 2 {
+		key, entry := repos.Content[i], resolveAlias(repos.Content[i+1])
+		loc := entryLocation{file: file, line: key.Line, fields: map[string]int{}}
+		if entry.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(entry.Content); j += 2 {
+				loc.fields[entry.Content[j].Value] = entry.Content[j].Line
+			}
+		}
+		l.entries[key.Value] = loc
+	}
+}
+
+// locate returns where the entry key, or the entry it was generated from by a matrix, was defined
+func (l *configLinter) locate(key string) entryLocation {
+	for k := key; ; {
+		if loc, ok := l.entries[k]; ok {
+			return loc
+		}
+		i := strings.LastIndexAny(k, "/@")
+		if i < 0 {
+			return entryLocation{}
+		}
+		k = k[:i]
+	}
+}
+
+// addEntryIssue reports a problem of an entry, at the line of field if the entry sets it
+func (l *configLinter) addEntryIssue(key, field, format string, args ...interface{}) {
+	loc := l.locate(key)
+	line := loc.line
+	if fieldLine, ok := loc.fields[field]; ok {
+		line = fieldLine
+	}
+	l.issues = append(l.issues, configIssue{File: loc.file, Line: line, Message: fmt.Sprintf("repo %s: ", key) + fmt.Sprintf(format, args...)})
+}
+
+// checkEntries checks the merged entries of a configuration
+func (l *configLinter) checkEntries(config *Config) {
+	entries := map[string]Repo{}
+	for key, entry := range config.Repos {
+		expanded := &Config{Repos: map[string]Repo{key: entry}}
+		if err := expandMatrices(expanded); err != nil {
+			l.addEntryIssue(key, "matrix", "%v", strings.TrimPrefix(err.Error(), "repo "+key+": "))
+			continue
+		}
+		for expandedKey, details := range expanded.Repos {
+			merged, err := mergeRepo(config.Defaults, details)
+			if err != nil {
+				l.addEntryIssue(key, "list_merge", "%v", err)
+				break
+			}
+			entries[expandedKey] = merged
+		}
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	targets := map[string]string{} // Entry key by provider, host, repository and head
+	for _, key := range keys {
+		details := entries[key]
+		selector, err := entrySelector(details)
+		if err != nil {
+			l.addEntryIssue(key, "repo", "%v", err)
+			continue
+		}
+
+		var missing []string
+		titleSet := l.locate(key).has("title") || l.defaults["title"]
+		for _, field := range missingFields(details) {
+			switch {
+			case selector != nil && (field == "repo" || field == "base"):
+				// Selectors provide the repository, and the default branch when base is not set
+			case field == "title" && titleSet:
+				l.addEntryIssue(key, "title", "title is empty")
+			default:
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			l.addEntryIssue(key, "", "missing required %s %s", plural(len(missing), "field", "fields"), strings.Join(missing, ", "))
+		}
+
+		if selector == nil && details.Repo != "" {
+			pattern := repoNamePattern
+			if providerName(details) == providerGitLab {
+				pattern = gitlabRepoNamePattern
+			}
+			if !pattern.MatchString(details.Repo) {
+				l.addEntryIssue(key, "repo", "invalid repository %q, expected \"owner/name\"", details.Repo)
+				continue
+			}
+		}
+
+		if selector != nil || details.Head == "" {
+			continue
+		}
+		head, err := renderTemplate("head", details.Head, newTemplateData(key, details))
+		if err != nil {
+			l.addEntryIssue(key, "head", "invalid head template: %v", err)
+			continue
+		}
+		target := strings.Join([]string{providerName(details), details.Host, strings.ToLower(details.Repo), head}, "\x00")
+		if other, ok := targets[target]; ok {
+			l.addEntryIssue(key, "repo", "repository %s with head %s is already used by entry %s", details.Repo, head, other)
+			continue
+		}
+		targets[target] = key
+	}
+}
+
+func (loc entryLocation) has(field string) bool {
+	_, ok := loc.fields[field]
+	return ok
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func lintFiles(t *testing.T, contents ...string) ([]string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	var files []string
+	for i, content := range contents {
+		file := filepath.Join(dir, string(rune('a'+i))+".yaml")
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	issues, err := lintConfigFiles(files)
+	if err != nil {
+		t.Fatalf("lintConfigFiles failed: %v", err)
+	}
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, strings.TrimPrefix(issue.String(), dir+string(filepath.Separator)))
+	}
+	return lines, files
+}
+
+func TestLintConfigUnknownFields(t *testing.T) {
+	issues, _ := lintFiles(t, `concurency: 4
+defaults:
+  head: dev
+  titel: Update
+repos:
+  one:
+    repo: org/one
+    base: main
+    title: One
+    reviewer: [bob]
+    selector:
+      owner: org
+      topic: go
+    edits:
+      - replace: {files: "*.go", pattern: a, with: b, count: 1}
+`)
+	want := []string{
+		`a.yaml:1: unknown field "concurency" at the top level`,
+		`a.yaml:4: unknown field "titel" in defaults`,
+		`a.yaml:10: unknown field "reviewer" in repos.one`,
+		`a.yaml:13: unknown field "topic" in repos.one.selector`,
+		`a.yaml:15: unknown field "count" in repos.one.edits[0].replace`,
+	}
+	if strings.Join(issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected issues:\n%s", strings.Join(issues, "\n"))
+	}
+}
+
+func TestLintConfigEntries(t *testing.T) {
+	issues, _ := lintFiles(t, `defaults:
+  head: chore/update
+repos:
+  ok:
+    repo: org/ok
+    base: main
+    title: OK
+  missing:
+    title: Missing
+  empty-title:
+    repo: org/empty
+    base: main
+    title: "  "
+  bad-name:
+    repo: org/a/b
+    base: main
+    title: Bad
+  nested-group:
+    repo: group/sub/project
+    provider: gitlab
+    base: main
+    title: Nested
+  duplicate:
+    repo: org/OK
+    base: develop
+    title: Duplicate
+  selected:
+    selector: {owner: org}
+    title: Selected
+  backport:
+    matrix:
+      repos: [org/api]
+      bases: [v1, v2]
+    head: "backport-{{ .Base }}"
+  broken-matrix:
+    matrix: {}
+    title: Broken
+`, `repos:
+  overridden:
+    repo: org/overridden
+`)
+	want := []string{
+		"a.yaml:5: repo ok: repository org/ok with head chore/update is already used by entry duplicate",
+		"a.yaml:8: repo missing: missing required fields repo, base",
+		"a.yaml:13: repo empty-title: title is empty",
+		`a.yaml:15: repo bad-name: invalid repository "org/a/b", expected "owner/name"`,
+		"a.yaml:30: repo backport/api@v1: missing required field title",
+		"a.yaml:30: repo backport/api@v2: missing required field title",
+		"a.yaml:36: repo broken-matrix: matrix needs repos or bases",
+		"b.yaml:2: repo overridden: missing required fields base, title",
+	}
+	if strings.Join(issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected issues:\n%s", strings.Join(issues, "\n"))
+	}
+}
+
+func TestLintConfigReportsEveryFile(t *testing.T) {
+	issues, _ := lintFiles(t, "repos:\n  a:\n    repo: org/a\n    base: main\n    head: dev\n    title: A\n",
+		"repos:\n  b:\n    repo: org/b\n    colour: red\n    draft: maybe\n")
+	want := []string{
+		`b.yaml:4: unknown field "colour" in repos.b`,
+		"b.yaml:5: cannot unmarshal !!str `maybe` into bool",
+	}
+	if strings.Join(issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected issues:\n%s", strings.Join(issues, "\n"))
+	}
+
+	// Errors of the merged configuration are reported at the file that causes them
+	issues, _ = lintFiles(t, "repos:\n  a:\n    repo: org/a\n    base: main\n    head: dev\n    title: A\n",
+		"defaults:\n  list_merge: sideways\n")
+	if len(issues) != 1 || !strings.HasPrefix(issues[0], "b.yaml:2: invalid defaults in file") {
+		t.Errorf("Expected the invalid defaults of b.yaml, got %v", issues)
+	}
+
+	// Unknown fields do not hide the problems of the entries
+	issues, _ = lintFiles(t, "repos:\n  a:\n    repo: org/a\n    base: main\n    head: dev\n    titel: A\n")
+	want = []string{
+		"a.yaml:2: repo a: missing required field title",
+		`a.yaml:6: unknown field "titel" in repos.a`,
+	}
+	if strings.Join(issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected issues:\n%s", strings.Join(issues, "\n"))
+	}
+}
+
+func TestLintConfigSyntaxError(t *testing.T) {
+	issues, _ := lintFiles(t, "repos:\n  a: [\n")
+	if len(issues) != 1 || !strings.Contains(issues[0], "a.yaml: yaml: line") {
+		t.Errorf("Expected a syntax error with its line, got %v", issues)
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	_, files := lintFiles(t, "repos:\n  a:\n    repo: org/a\n    base: main\n    head: dev\n    title: A\n    draf: true\n")
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	var err error
+	captureStdout(t, func() { err = runValidate(files) })
+	if err == nil || !strings.Contains(logged.String(), `:7: unknown field "draf" in repos.a`) {
+		t.Errorf("Expected the unknown field to be logged, got %q and %v", logged.String(), err)
+	}
+
+	useMemoryBackend(t)
+	valid := strings.Replace(readFile(t, filepath.Dir(files[0]), "a.yaml"), "draf:", "draft:", 1)
+	if err := os.WriteFile(files[0], []byte(valid), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := captureStdout(t, func() { err = runValidate(files) })
+	if err != nil || !strings.Contains(output, "All 1 entries are valid") {
+		t.Errorf("Expected a valid configuration, got %q and %v", output, err)
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
-	"strings" // Required for strings.Join
-	"sync"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -31,12 +36,69 @@ type Repo struct {
 	Assignees []string `yaml:"assignees,omitempty"`
 	Reviewers []string `yaml:"reviewers,omitempty"`
 	Draft     bool     `yaml:"draft,omitempty"`
+	// Vars are user-defined values available to templates as {{ .Vars.name }}
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// ListMerge controls how labels, assignees and reviewers combine with the defaults: "append" or "replace"
+	ListMerge string `yaml:"list_merge,omitempty"`
+	// Provider is the forge hosting the repository: "github" (default), "gitlab", "gitea", "forgejo" or "bitbucket"
+	Provider string `yaml:"provider,omitempty"`
+	// Host is the forge host for self-hosted instances, e.g. "gitlab.example.com"
+	Host string `yaml:"host,omitempty"`
+	// AutoMerge enables auto-merge with this merge method ("merge", "squash" or "rebase") once the PR is created
+	AutoMerge string `yaml:"auto_merge,omitempty"`
+	// MergeOrder groups entries for the merge command: lower orders are merged first
+	MergeOrder int `yaml:"merge_order,omitempty"`
+	// Selector makes the entry target every matching repository of an owner instead of `repo`
+	Selector *repoSelector `yaml:"selector,omitempty"`
+	// Matrix makes the entry target every combination of several repositories and base branches
+	Matrix *repoMatrix `yaml:"matrix,omitempty"`
+	// Script is a shell command that makes the change on a fresh clone; the result is pushed to the head branch
+	Script string `yaml:"script,omitempty"`
+	// CommitMessage is the message of the commit made from the script's changes (default: the title)
+	CommitMessage string `yaml:"commit_message,omitempty"`
+	// Author of that commit as "Name <email>" (default: the git configuration)
+	Author string `yaml:"author,omitempty"`
+	// Edits are declarative file changes applied to a fresh clone, before the script
+	Edits []fileEdit `yaml:"edits,omitempty"`
+	// Path is the directory of the repository's clone, relative to the workspace
+	Path string `yaml:"path,omitempty"`
+
+	draftSet bool // Whether `draft` was present in the YAML, see UnmarshalYAML
 }
 
 type Config struct {
-	Repos map[string]Repo `yaml:"repos"`
+	// Defaults are inherited by every entry in Repos
+	Defaults Repo            `yaml:"defaults,omitempty"`
+	Repos    map[string]Repo `yaml:"repos"`
+	// OnExisting is the policy for an already open PR with the same base and head: "fail", "skip" or "update"
+	OnExisting string `yaml:"on_existing,omitempty"`
+	// Concurrency is the maximum number of repositories processed at the same time
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// Delay is the minimum time between two PR creations, e.g. "2s"
+	Delay time.Duration `yaml:"delay,omitempty"`
+	// Retries is the number of retries for transient failures (rate limits, network errors, timeouts)
+	Retries *int `yaml:"retries,omitempty"`
+	// Timeout bounds every single command, e.g. "2m"
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Output is the report format: "text", "json" or "ndjson"
+	Output string `yaml:"output,omitempty"`
+	// State is the path of the campaign state file recording what was created
+	State string `yaml:"state,omitempty"`
+	// Backend selects how GitHub is reached: "gh" (the gh CLI) or "api" (the REST API)
+	Backend string `yaml:"backend,omitempty"`
+	// Workspace is a directory of local clones whose head branches are pushed before the PRs are opened
+	Workspace string `yaml:"workspace,omitempty"`
+	// Preflight checks every entry against the forge before anything is created (default: true)
+	Preflight *bool `yaml:"preflight,omitempty"`
+	// KeepGoing creates the PRs of the entries that passed the preflight checks even if others failed (set from --keep-going)
+	KeepGoing bool `yaml:"-"`
+	// Resume skips entries the state file records as done with an unchanged configuration (set from --resume)
+	Resume bool `yaml:"-"`
 }
 
+// errNoRepositories is returned by readYAMLConfig when the files have no entries
+var errNoRepositories = errors.New("No repositories found in any configuration files")
+
 // readYAMLConfig reads YAML files and merges them into a single Config struct
 func readYAMLConfig(filenames []string) (*Config, error) {
 	mergedConfig := &Config{Repos: make(map[string]Repo)}
@@ -56,10 +118,46 @@ func readYAMLConfig(filenames []string) (*Config, error) {
 		for key, repo := range config.Repos {
 			mergedConfig.Repos[key] = repo
 		}
+
+		if config.OnExisting != "" {
+			mergedConfig.OnExisting = config.OnExisting
+		}
+		if config.Concurrency != 0 {
+			mergedConfig.Concurrency = config.Concurrency
+		}
+		if config.Delay != 0 {
+			mergedConfig.Delay = config.Delay
+		}
+		if config.Retries != nil {
+			mergedConfig.Retries = config.Retries
+		}
+		if config.Timeout != 0 {
+			mergedConfig.Timeout = config.Timeout
+		}
+		if config.Output != "" {
+			mergedConfig.Output = config.Output
+		}
+		if config.State != "" {
+			mergedConfig.State = config.State
+		}
+		if config.Backend != "" {
+			mergedConfig.Backend = config.Backend
+		}
+		if config.Workspace != "" {
+			mergedConfig.Workspace = config.Workspace
+		}
+		if config.Preflight != nil {
+			mergedConfig.Preflight = config.Preflight
+		}
+
+		mergedConfig.Defaults, err = mergeRepo(mergedConfig.Defaults, config.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("invalid defaults in file %s: %w", filename, err)
+		}
 	}
 
 	if len(mergedConfig.Repos) == 0 {
-		return nil, fmt.Errorf("No repositories found in any configuration files")
+		return nil, errNoRepositories
 	}
 
 	return mergedConfig, nil
@@ -67,152 +165,381 @@ func readYAMLConfig(filenames []string) (*Config, error) {
 
 var mockRunCommand func(args ...string) error
 
-// runCommand executes a shell command in a given directory
-func runCommand(args ...string) error {
+// runCommand executes a command, streaming its output while capturing stderr for error classification
+func runCommand(ctx context.Context, args ...string) error {
 	if mockRunCommand != nil {
 		return mockRunCommand(args...)
 	}
 
-	cmd := exec.Command(args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("Error running command %s: %v", args, err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return newCommandError(args, stderr.String(), err)
 	}
 
 	return nil
 }
 
+var mockRunCommandOutput func(args ...string) ([]byte, error)
+
+// runCommandOutput executes a command and returns its standard output
+func runCommandOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return runCommandIn(ctx, "", nil, args...)
+}
+
+// runCommandIn executes a command in dir, with env added to the environment, and returns its standard output
+func runCommandIn(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	if mockRunCommandOutput != nil {
+		return mockRunCommandOutput(args...)
+	}
+	if mockRunCommand != nil {
+		return nil, mockRunCommand(args...)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, newCommandError(args, stderr.String(), err)
+	}
+
+	return out, nil
+}
+
 // createPullRequest generates a PR for each repository in the YAML file
 func createPullRequest(config *Config, dryRun bool) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(config.Repos))
-	attemptedPRs := 0
+	repoNames, prepared, err := prepareCampaign(config)
+	if err != nil {
+		return err
+	}
+	_, err = createPrepared(config, repoNames, prepared, dryRun)
+	return err
+}
+
+// prepareCampaign checks the settings of a campaign and prepares its entries
+func prepareCampaign(config *Config) ([]string, map[string]Repo, error) {
+	if err := validateExistingPolicy(config.OnExisting); err != nil {
+		return nil, nil, err
+	}
+	if err := validateOutputFormat(config.Output); err != nil {
+		return nil, nil, err
+	}
+	if err := validateBackend(config.Backend); err != nil {
+		return nil, nil, err
+	}
+	if config.KeepGoing && !config.preflightEnabled() {
+		return nil, nil, fmt.Errorf("--keep-going requires the preflight checks, which are disabled")
+	}
+	if config.Resume && config.State == "" {
+		return nil, nil, fmt.Errorf("--resume requires a state file (--state or `state:` in the configuration)")
+	}
+
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validatePrepared(config, repoNames, prepared); err != nil {
+		return nil, nil, err
+	}
+	return repoNames, prepared, nil
+}
 
-	repoNames := make([]string, 0, len(config.Repos))
-	for name := range config.Repos {
-		repoNames = append(repoNames, name)
+// createPrepared generates a PR for each prepared entry. It returns the entries that have a PR after the run:
+// those whose PR was created or updated, and those skipped because of one.
+func createPrepared(config *Config, repoNames []string, prepared map[string]Repo, dryRun bool) (map[string]Repo, error) {
+	onExisting := config.OnExisting
+	if onExisting == "" {
+		onExisting = existingFail
 	}
 
+	var err error
+	var state *campaignState
+	if config.State != "" {
+		if state, err = loadState(config.State); err != nil {
+			return nil, err
+		}
+	}
+
+	limiter := newThrottle(config.Delay)
+	retry := newRetrier(config)
+	ctx := context.Background()
+
+	rep := newReporter(os.Stdout, config.Output)
 	for _, repoName := range repoNames {
-		details := config.Repos[repoName]
+		if _, ok := prepared[repoName]; !ok {
+			details := config.Repos[repoName]
+			rep.add(newResult(repoName, details).finish(statusInvalid, fmt.Errorf("missing required fields: %s", strings.Join(missingFields(details), ", "))))
+		}
+	}
 
-		bodyContent, err := os.ReadFile(details.Body)
-		if err == nil {
-			details.Body = string(bodyContent)
-			config.Repos[repoName] = details
-		} else {
-			if !os.IsNotExist(err) {
-				log.Printf("Warning: PR body file '%s' for repo '%s' exists but is unreadable: %v. Using raw string as body.", details.Body, repoName, err)
+	// Dry runs create nothing, so they are not checked; `validate --remote` runs the same checks
+	var preflightFailures map[string]error
+	if config.preflightEnabled() && !dryRun {
+		entries := preflightEntries(ctx, config, state, onExisting, retry, repoNames, prepared)
+		preflightFailures, _ = runPreflight(config, repoNames, entries)
+		if len(preflightFailures) > 0 && !config.KeepGoing {
+			// The report still lists every entry, so that consumers see why nothing was created
+			for _, repoName := range repoNames {
+				details, ok := prepared[repoName]
+				if !ok {
+					continue
+				}
+				res := newResult(repoName, details)
+				if err, failed := preflightFailures[repoName]; failed {
+					rep.add(res.finish(statusFailed, err))
+				} else {
+					rep.add(res.finish(statusSkipped, fmt.Errorf("not attempted because the preflight checks of other entries failed")))
+				}
+			}
+			if err := rep.finish(); err != nil {
+				log.Printf("Failed to write report: %v\n", err)
 			}
+			return nil, fmt.Errorf("preflight checks failed for %d entries, nothing was created (use --keep-going to create the others)", len(preflightFailures))
 		}
+	}
 
-		if details.Repo == "" || details.Base == "" || details.Head == "" {
-			log.Printf("Invalid repository configuration for %s, skipping\n", repoName)
-			continue
+	stdout := progressOutput(config.Output)
+	processRepo := func(repoName string, currentDetails Repo) result {
+		res := newResult(repoName, currentDetails)
+		if err, ok := preflightFailures[repoName]; ok {
+			return res.finish(statusFailed, err)
 		}
-		attemptedPRs++
+		log.Printf("Processing PR for %s (base: %s, head: %s)...\n", repoName, currentDetails.Base, currentDetails.Head)
 
-		wg.Add(1)
-		go func(repoName string, currentDetails Repo) {
-			defer wg.Done()
+		be, err := backendFor(config, currentDetails)
+		if err == nil {
+			err = checkSupportedFields(be, currentDetails)
+		}
+		if err != nil {
+			log.Printf("Invalid repository configuration for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("%s: %w", repoName, err))
+		}
 
-			log.Printf("Processing PR for %s (base: %s, head: %s)...\n", repoName, currentDetails.Base, currentDetails.Head)
+		if entry, ok := resumedEntry(config, state, repoName, currentDetails); ok {
+			fmt.Fprintf(stdout, "Already done for %s in a previous run (%s), skipping\n", repoName, entry.URL)
+			res.Number, res.URL = entry.Number, entry.URL
+			return res.finish(statusSkipped, nil)
+		}
 
-			// Arguments for display in dry run (quoted)
-			displayCmdParts := []string{"gh", "pr", "create"}
-			if currentDetails.Draft {
-				displayCmdParts = append(displayCmdParts, "--draft")
+		if config.Workspace != "" {
+			dir, err := localClone(config.Workspace, currentDetails)
+			var action string
+			if err == nil {
+				action, err = syncLocalBranch(ctx, retry, dir, currentDetails, dryRun)
 			}
-			displayCmdParts = append(displayCmdParts, "--title", fmt.Sprintf("%q", currentDetails.Title))
-			displayCmdParts = append(displayCmdParts, "--body", fmt.Sprintf("%q", currentDetails.Body))
-			displayCmdParts = append(displayCmdParts, "--base", fmt.Sprintf("%q", currentDetails.Base))
-			displayCmdParts = append(displayCmdParts, "--head", fmt.Sprintf("%q", currentDetails.Head))
-			for _, label := range currentDetails.Labels {
-				displayCmdParts = append(displayCmdParts, "--label", fmt.Sprintf("%q", label))
+			if err != nil {
+				log.Printf("Failed to push the local branch for %s: %v\n", repoName, err)
+				return res.finish(statusFailed, fmt.Errorf("failed to push the local branch for %s: %w", repoName, err))
 			}
-			for _, assignee := range currentDetails.Assignees {
-				displayCmdParts = append(displayCmdParts, "--assignee", fmt.Sprintf("%q", assignee))
+			fmt.Fprintf(stdout, "Local clone of %s: %s\n", repoName, action)
+		}
+
+		if dryRun {
+			if hasChange(currentDetails) {
+				fmt.Fprintf(stdout, "DRY RUN: Would %s\n", describeChange(currentDetails))
 			}
-			for _, reviewer := range currentDetails.Reviewers {
-				displayCmdParts = append(displayCmdParts, "--reviewer", fmt.Sprintf("%q", reviewer))
+			if len(currentDetails.Edits) > 0 {
+				diff, err := previewChange(ctx, retry, currentDetails)
+				if err != nil {
+					log.Printf("Failed to preview edits for %s: %v\n", repoName, err)
+					return res.finish(statusFailed, fmt.Errorf("failed to preview edits for %s: %w", repoName, err))
+				}
+				if diff == "" {
+					fmt.Fprintf(stdout, "DRY RUN: Edits change nothing for %s\n", repoName)
+				} else {
+					fmt.Fprintf(stdout, "DRY RUN: Edits for %s:\n%s", repoName, diff)
+				}
 			}
-			displayCmdParts = append(displayCmdParts, "--repo", fmt.Sprintf("%q", currentDetails.Repo))
+			fmt.Fprintf(stdout, "DRY RUN: Would execute: %s\n", be.describeCreate(currentDetails))
+			if currentDetails.AutoMerge != "" {
+				fmt.Fprintf(stdout, "DRY RUN: Would enable auto-merge (%s) for %s\n", currentDetails.AutoMerge, repoName)
+			}
+			return res.finish(statusDryRun, nil)
+		}
 
-			// Arguments for actual execution (not double-quoted)
-			execCmdArgs := []string{"gh", "pr", "create"}
-			if currentDetails.Draft {
-				execCmdArgs = append(execCmdArgs, "--draft")
+		var existing *pullRequest
+		err = retry.do(ctx, "Looking up existing PR for "+repoName, func(ctx context.Context) (err error) {
+			existing, err = be.findPullRequest(ctx, currentDetails)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to check for an existing PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to check for an existing PR for %s: %w", repoName, err))
+		}
+		if existing != nil {
+			res.Number, res.URL = existing.Number, existing.URL
+			switch onExisting {
+			case existingSkip:
+				fmt.Fprintf(stdout, "PR already exists for %s (%s), skipping\n", repoName, existing.URL)
+				return res.finish(statusSkipped, nil)
+			case existingFail:
+				return res.finish(statusFailed, withClass(classAlreadyExists, fmt.Errorf("PR already exists for %s: %s", repoName, existing.URL)))
 			}
-			execCmdArgs = append(execCmdArgs,
-				"--title", currentDetails.Title,
-				"--body", currentDetails.Body,
-				"--base", currentDetails.Base,
-				"--head", currentDetails.Head)
-			for _, label := range currentDetails.Labels {
-				execCmdArgs = append(execCmdArgs, "--label", label)
+		}
+
+		if hasChange(currentDetails) {
+			fmt.Fprintf(stdout, "Applying change for %s...\n", repoName)
+			owned := existing != nil || state.ownsHead(repoName, currentDetails)
+			changed, err := applyChange(ctx, retry, repoName, currentDetails, owned)
+			if err != nil {
+				log.Printf("Failed to apply change for %s: %v\n", repoName, err)
+				return res.finish(statusFailed, fmt.Errorf("failed to apply change for %s: %w", repoName, err))
 			}
-			for _, assignee := range currentDetails.Assignees {
-				execCmdArgs = append(execCmdArgs, "--assignee", assignee)
+			if !changed {
+				fmt.Fprintf(stdout, "No changes for %s, skipping\n", repoName)
+				return res.finish(statusNoChanges, nil)
 			}
-			for _, reviewer := range currentDetails.Reviewers {
-				execCmdArgs = append(execCmdArgs, "--reviewer", reviewer)
+		}
+
+		if existing != nil {
+			limiter.wait()
+			err := retry.do(ctx, "Updating PR for "+repoName, func(ctx context.Context) error {
+				return be.updatePullRequest(ctx, currentDetails, existing)
+			})
+			if err != nil {
+				log.Printf("Failed to update existing PR for %s: %v\n", repoName, err)
+				return res.finish(statusFailed, fmt.Errorf("failed to update existing PR for %s: %w", repoName, err))
 			}
-			execCmdArgs = append(execCmdArgs, "--repo", currentDetails.Repo)
-
-			if dryRun {
-				fmt.Printf("DRY RUN: Would execute: %s\n", strings.Join(displayCmdParts, " "))
-				errChan <- nil
-			} else {
-				fmt.Printf("Creating PR for %s (base: %s, head: %s)...\n", repoName, currentDetails.Base, currentDetails.Head)
-				err := runCommand(execCmdArgs...)
-				if err != nil {
-					log.Printf("Failed to create PR for %s: %v\n", repoName, err)
-					errChan <- fmt.Errorf("failed to create PR for %s: %w", repoName, err)
-				} else {
-					fmt.Printf("PR created for %s successfully!\n", repoName)
-					errChan <- nil
+			fmt.Fprintf(stdout, "PR updated for %s (%s)\n", repoName, existing.URL)
+			applyAutoMerge(ctx, config, retry, currentDetails, &res)
+			return res.finish(statusUpdated, nil)
+		}
+
+		limiter.wait()
+		fmt.Fprintf(stdout, "Creating PR for %s (base: %s, head: %s)...\n", repoName, currentDetails.Base, currentDetails.Head)
+		var created *pullRequest
+		var followUpErr, createErr error
+		err = retry.do(ctx, "Creating PR for "+repoName, func(ctx context.Context) (err error) {
+			// A create that timed out or lost its connection may still have gone through
+			if class := classOf(createErr); class == classTimeout || class == classNetwork {
+				found, err := be.findPullRequest(ctx, currentDetails)
+				if err != nil || found != nil {
+					created = found
+					return err
 				}
 			}
-		}(repoName, details)
+			created, err = be.createPullRequest(ctx, currentDetails)
+			if created != nil && err != nil {
+				// The PR exists, so retrying would only fail with "already exists"
+				followUpErr, err = err, nil
+			}
+			createErr = err
+			return err
+		})
+		if err == nil {
+			err = followUpErr
+		}
+		if created != nil {
+			res.URL, res.Number = created.URL, created.Number
+		}
+		if err != nil {
+			log.Printf("Failed to create PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to create PR for %s: %w", repoName, err))
+		}
+		if res.URL != "" {
+			fmt.Fprintf(stdout, "PR created for %s successfully! %s\n", repoName, res.URL)
+		} else {
+			fmt.Fprintf(stdout, "PR created for %s successfully!\n", repoName)
+		}
+		applyAutoMerge(ctx, config, retry, currentDetails, &res)
+		return res.finish(statusCreated, nil)
 	}
 
-	wg.Wait()
-	close(errChan)
+	resultChan := make(chan result, len(config.Repos))
+	attemptedPRs := forEachRepo(config.Concurrency, repoNames, prepared, func(repoName string, details Repo) {
+		res := processRepo(repoName, details)
+		if state != nil && !dryRun {
+			if err := state.record(res, configHash(details)); err != nil {
+				log.Printf("Warning: %v\n", err)
+			}
+		}
+		rep.add(res)
+		resultChan <- res
+	})
+	close(resultChan)
+
+	if err := rep.finish(); err != nil {
+		log.Printf("Failed to write report: %v\n", err)
+	}
 
 	if attemptedPRs == 0 && len(config.Repos) > 0 {
-		return fmt.Errorf("no valid repository configurations found to attempt PR creation, though %d configurations were present", len(config.Repos))
+		return nil, fmt.Errorf("no valid repository configurations found to attempt PR creation, though %d configurations were present", len(config.Repos))
 	}
 
-	var firstError error
-	hasFailures := false
-	for err := range errChan {
-		if err != nil {
-			if !hasFailures {
-				firstError = err
-			}
-			hasFailures = true
+	withPR := map[string]Repo{}
+	var failures, autoMergeFailures []result
+	for res := range resultChan {
+		if res.Status == statusCreated || res.Status == statusUpdated || (res.Status == statusSkipped && res.URL != "") {
+			withPR[res.Key] = prepared[res.Key]
+		}
+		if res.Status == statusFailed {
+			failures = append(failures, res)
+		}
+		if res.AutoMerge == autoMergeFailed {
+			autoMergeFailures = append(autoMergeFailures, res)
 		}
 	}
 
-	if hasFailures {
-		return fmt.Errorf("one or more pull requests failed to process or create (first error: %w)", firstError)
+	if len(autoMergeFailures) > 0 {
+		sort.Slice(autoMergeFailures, func(i, j int) bool { return autoMergeFailures[i].Key < autoMergeFailures[j].Key })
+		log.Printf("Auto-merge could not be enabled for %d pull requests:\n", len(autoMergeFailures))
+		for _, res := range autoMergeFailures {
+			log.Printf("  %s %s: %s\n", res.Key, res.URL, res.AutoMergeError)
+		}
 	}
 
-	return nil
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Key < failures[j].Key })
+		log.Printf("%d of %d pull requests failed:\n", len(failures), attemptedPRs)
+		for _, res := range failures {
+			log.Printf("  %s [%s] %s\n", res.Key, res.ErrorClass, res.Error)
+		}
+		return withPR, fmt.Errorf("one or more pull requests failed to process or create (first error: %w)", failures[0].err)
+	}
+
+	return withPR, nil
 }
 
 func main() {
+	if runCommandLine(os.Args[1:]) {
+		return
+	}
+
 	help := flag.Bool("help", false, "Show help")
 	version := flag.Bool("version", false, "Show version")
 	dryRun := flag.Bool("dry-run", false, "Simulate PR creation without executing commands")
+	flags := addCampaignFlags(flag.CommandLine)
+	delay := flag.Duration("delay", 0, "Minimum delay between two PR creations, e.g. 2s")
+	statePath := flag.String("state", "", "Path of the campaign state file recording created PRs")
+	resume := flag.Bool("resume", false, "Only process entries that are missing, failed or changed in the state file")
+	wait := flag.Bool("wait", false, "After creating the PRs, wait until their checks completed and report which passed")
+	waitFlags := addWatchFlags(flag.CommandLine)
+	noPreflight := flag.Bool("no-preflight", false, "Do not check every entry against the forge before creating anything")
+	keepGoing := flag.Bool("keep-going", false, "Create the PRs of the entries that passed the preflight checks even if others failed")
+	workspace := flag.String("workspace", "", "Directory of local clones whose head branches are pushed before the PRs are opened")
+	onExisting := flag.String("on-existing", "", "What to do when an open PR already exists for the same base and head: skip, update or fail (default fail)")
 
 	flag.Parse()
 
 	if *help {
-		fmt.Println("Usage: gh bulkpr <config-file1> [config-file2] ...")
+		fmt.Println("Usage: gh bulkpr [command] <config-file1> [config-file2] ...")
 		fmt.Println("Create pull requests in multiple repositories using one or more configuration files.")
+		printCommands()
 		fmt.Println("\nFlags:")
 		flag.PrintDefaults()
 		osExit(0)
@@ -233,8 +560,40 @@ func main() {
 		logFatalf("Error reading config files: %v", err)
 	}
 
-	err = createPullRequest(config, *dryRun)
+	flags.apply(config)
+	if *onExisting != "" {
+		config.OnExisting = *onExisting
+	}
+	if *delay > 0 {
+		config.Delay = *delay
+	}
+	if *statePath != "" {
+		config.State = *statePath
+	}
+	if *workspace != "" {
+		config.Workspace = *workspace
+	}
+	if *noPreflight {
+		enabled := false
+		config.Preflight = &enabled
+	}
+	config.KeepGoing = *keepGoing
+	config.Resume = *resume
+
+	repoNames, prepared, err := prepareCampaign(config)
+	var withPR map[string]Repo
+	if err == nil {
+		withPR, err = createPrepared(config, repoNames, prepared, *dryRun)
+	}
 	if err != nil {
 		logFatalf("Error creating pull requests: %v", err)
 	}
+
+	if *wait && !*dryRun {
+		// The entries were prepared already, so defaults are not merged a second time.
+		// Only entries with a PR are watched, e.g. not those without changes.
+		if err := watchPrepared(config, repoNames, withPR, waitFlags.options()); err != nil {
+			logFatalf("Error waiting for checks: %v", err)
+		}
+	}
 }
@@ -97,7 +97,8 @@ func TestCreatePullRequest(t *testing.T) {
 	t.Run("SingleRepoSuccess_NonDryRun", func(t *testing.T) {
 		mockRunCommand = func(args ...string) error { return nil }
 		configSingle := &Config{
-			Repos: map[string]Repo{"test-repo-1": {Repo: "org/test-repo-1", Base: "main", Head: "feature", Title: "Test PR 1", Body: "Body", Labels: nil, Assignees: nil, Reviewers: nil, Draft: false}},
+			Preflight: new(bool),
+			Repos:     map[string]Repo{"test-repo-1": {Repo: "org/test-repo-1", Base: "main", Head: "feature", Title: "Test PR 1", Body: "Body", Labels: nil, Assignees: nil, Reviewers: nil, Draft: false}},
 		}
 		if err := createPullRequest(configSingle, false); err != nil {
 			t.Errorf("createPullRequest with single repo (non-dry) failed: %v", err)
@@ -107,6 +108,7 @@ func TestCreatePullRequest(t *testing.T) {
 	t.Run("MultipleReposSuccess_NonDryRun", func(t *testing.T) {
 		mockRunCommand = func(args ...string) error { return nil }
 		configMultiple := &Config{
+			Preflight: new(bool),
 			Repos: map[string]Repo{
 				"test-repo-1": {Repo: "org/test-repo-1", Base: "main", Head: "f1", Title: "T1", Body: "B1", Labels: []string{}, Assignees: []string{}, Reviewers: []string{}, Draft: false},
 				"test-repo-2": {Repo: "org/test-repo-2", Base: "dev", Head: "f2", Title: "T2", Body: "B2", Labels: nil, Assignees: nil, Reviewers: nil, Draft: true},
@@ -189,7 +191,7 @@ func TestCreatePullRequest(t *testing.T) {
 			copy(capturedArgs, args)
 			return nil
 		}
-		config := &Config{Repos: map[string]Repo{"repo-draft-actual1": {Repo: "org/draft-actual1", Base: "main", Head: "feature", Title: "Actual Draft Test", Body: "Body", Draft: true}}}
+		config := &Config{Preflight: new(bool), Repos: map[string]Repo{"repo-draft-actual1": {Repo: "org/draft-actual1", Base: "main", Head: "feature", Title: "Actual Draft Test", Body: "Body", Draft: true}}}
 		err := createPullRequest(config, false)
 		if err != nil {
 			t.Fatalf("Expected nil error for actual run with draft, got %v", err)
@@ -214,7 +216,7 @@ func TestCreatePullRequest(t *testing.T) {
 			copy(capturedArgs, args)
 			return nil
 		}
-		config := &Config{Repos: map[string]Repo{"repo-draft-actual2": {Repo: "org/draft-actual2", Base: "main", Head: "feature", Title: "Actual Non-Draft Test", Body: "Body", Draft: false}}}
+		config := &Config{Preflight: new(bool), Repos: map[string]Repo{"repo-draft-actual2": {Repo: "org/draft-actual2", Base: "main", Head: "feature", Title: "Actual Non-Draft Test", Body: "Body", Draft: false}}}
 		err := createPullRequest(config, false)
 		if err != nil {
 			t.Fatalf("Expected nil error for actual run non-draft, got %v", err)
@@ -308,7 +310,7 @@ func TestMainExecutionWithPartialSuccess(t *testing.T) {
 	configFile2 := createTempYAMLFile(t, `repos: {repo-fail: {repo: "org/repo-fail", base: "main", head: "dev-f", title: "F", body: "B"}}`)
 	defer os.Remove(configFile2)
 
-	os.Args = []string{"bulkpr", configFile1, configFile2}
+	os.Args = []string{"bulkpr", "--no-preflight", configFile1, configFile2}
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 
 	recovered := false
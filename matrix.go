@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// repoMatrix fans an entry out to every combination of repositories and base branches
+type repoMatrix struct {
+	Repos []string `yaml:"repos,omitempty"` // Repositories as owner/name, globs allowed
+	Bases []string `yaml:"bases,omitempty"` // Base branches
+}
+
+// expandMatrices replaces every entry with a `matrix:` by one entry per combination of its repositories and bases.
+// Generated keys are "<entry key>/<repository name>", "<entry key>@<base>" or "<entry key>/<repository name>@<base>",
+// where repositories with the same name under different owners are named "<owner>-<name>".
+// It runs before the defaults are applied, so an entry cannot set both `repo` and `matrix.repos` (or `base` and `matrix.bases`).
+func expandMatrices(config *Config) error {
+	keys := make([]string, 0, len(config.Repos))
+	for key := range config.Repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// The entries are expanded into a new map, so that generated keys are checked against the original entries only
+	// and the configuration is left as it was when an entry is invalid
+	repos := make(map[string]Repo, len(config.Repos))
+	for _, key := range keys {
+		details := config.Repos[key]
+		matrix := details.Matrix
+		if matrix == nil {
+			repos[key] = details
+			continue
+		}
+		if len(matrix.Repos) == 0 && len(matrix.Bases) == 0 {
+			return fmt.Errorf("repo %s: matrix needs repos or bases", key)
+		}
+		if len(matrix.Repos) > 0 && (details.Repo != "" || details.Selector != nil) {
+			return fmt.Errorf("repo %s: set either repo, selector or matrix.repos", key)
+		}
+		if len(matrix.Bases) > 0 && details.Base != "" {
+			return fmt.Errorf("repo %s: set either base or matrix.bases", key)
+		}
+
+		matrixRepos := matrix.Repos
+		if len(matrixRepos) == 0 {
+			matrixRepos = []string{details.Repo}
+		}
+		bases := matrix.Bases
+		if len(bases) == 0 {
+			bases = []string{details.Base}
+		}
+
+		owners := map[string]int{}
+		for _, repo := range matrix.Repos {
+			owner, name, ok := strings.Cut(repo, "/")
+			if !ok || owner == "" || name == "" {
+				return fmt.Errorf("repo %s: invalid matrix repository %q, expected \"owner/name\"", key, repo)
+			}
+			owners[name]++
+		}
+
+		for _, repo := range matrixRepos {
+			for _, base := range bases {
+				expandedKey := key
+				if len(matrix.Repos) > 0 {
+					owner, name, _ := strings.Cut(repo, "/")
+					if owners[name] > 1 {
+						name = owner + "-" + name
+					}
+					expandedKey += "/" + name
+				}
+				if len(matrix.Bases) > 0 {
+					if base == "" {
+						return fmt.Errorf("repo %s: empty matrix base", key)
+					}
+					expandedKey += "@" + base
+				}
+				_, original := config.Repos[expandedKey]
+				if _, generated := repos[expandedKey]; original || generated {
+					return fmt.Errorf("repo %s: matrix entry %s conflicts with an existing entry", key, expandedKey)
+				}
+
+				expanded := details
+				expanded.Matrix = nil
+				expanded.Repo, expanded.Base = repo, base
+				repos[expandedKey] = expanded
+			}
+		}
+	}
+	config.Repos = repos
+	return nil
+}
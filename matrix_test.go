@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpandMatrices(t *testing.T) {
+	config := &Config{
+		Defaults: Repo{Head: "backport/fix-{{ .Base }}", Title: "Backport fix to {{ .Base }}", Labels: []string{"backport"}},
+		Repos: map[string]Repo{
+			"backport": {Matrix: &repoMatrix{Repos: []string{"org/api", "org/web"}, Bases: []string{"release-1.x", "release-2.x"}}},
+			"bump":     {Matrix: &repoMatrix{Repos: []string{"org/api", "org/cli"}}, Base: "main", Head: "bump"},
+			"docs":     {Repo: "org/docs", Matrix: &repoMatrix{Bases: []string{"v1", "v2"}}},
+		},
+	}
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		t.Fatalf("prepareRepos failed: %v", err)
+	}
+
+	want := "[backport/api@release-1.x backport/api@release-2.x backport/web@release-1.x backport/web@release-2.x bump/api bump/cli docs@v1 docs@v2]"
+	if fmt.Sprint(repoNames) != want {
+		t.Errorf("Expected %s, got %v", want, repoNames)
+	}
+	got := prepared["backport/web@release-2.x"]
+	if got.Repo != "org/web" || got.Base != "release-2.x" || got.Head != "backport/fix-release-2.x" || got.Labels[0] != "backport" || got.Matrix != nil {
+		t.Errorf("Unexpected matrix entry: %+v", got)
+	}
+	if got := prepared["docs@v2"]; got.Repo != "org/docs" || got.Base != "v2" {
+		t.Errorf("Unexpected matrix entry: %+v", got)
+	}
+}
+
+func TestExpandMatricesSameName(t *testing.T) {
+	// Repositories with the same name are told apart by their owner, the others keep their name
+	config := &Config{Repos: map[string]Repo{
+		"x": {Matrix: &repoMatrix{Repos: []string{"org-a/api", "org-b/api", "org-a/web"}, Bases: []string{"main"}}},
+	}}
+	if err := expandMatrices(config); err != nil {
+		t.Fatalf("expandMatrices failed: %v", err)
+	}
+	if got := config.Repos["x/org-b-api@main"]; got.Repo != "org-b/api" {
+		t.Errorf("Unexpected entries: %v", config.Repos)
+	}
+	if got := config.Repos["x/org-a-api@main"]; got.Repo != "org-a/api" {
+		t.Errorf("Unexpected entries: %v", config.Repos)
+	}
+	if got := config.Repos["x/web@main"]; got.Repo != "org-a/web" || len(config.Repos) != 3 {
+		t.Errorf("Unexpected entries: %v", config.Repos)
+	}
+}
+
+func TestExpandMatricesErrors(t *testing.T) {
+	for name, entries := range map[string]map[string]Repo{
+		"empty":          {"x": {Matrix: &repoMatrix{}}},
+		"repo and repos": {"x": {Repo: "org/a", Matrix: &repoMatrix{Repos: []string{"org/b"}}}},
+		"base and bases": {"x": {Base: "main", Matrix: &repoMatrix{Bases: []string{"v1"}}}},
+		"invalid repo":   {"x": {Matrix: &repoMatrix{Repos: []string{"api"}}}},
+		"conflict":       {"x": {Matrix: &repoMatrix{Repos: []string{"org/api"}}}, "x/api": {Repo: "org/api"}},
+		"same repo":      {"x": {Matrix: &repoMatrix{Repos: []string{"org/api", "org/api"}}}},
+	} {
+		config := &Config{Repos: entries}
+		if err := expandMatrices(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if config.Repos["x"].Matrix == nil {
+			t.Errorf("%s: expected the entries to be left as they were", name)
+		}
+	}
+}
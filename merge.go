@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Merge methods of the merge command
+const (
+	mergeMethodMerge  = "merge"
+	mergeMethodSquash = "squash"
+	mergeMethodRebase = "rebase"
+)
+
+// Statuses of an entry in the report of the merge command
+const (
+	statusMerged  = "merged"
+	statusBlocked = "blocked"
+)
+
+// validateMergeMethod checks the value of --method
+func validateMergeMethod(method string) error {
+	switch method {
+	case mergeMethodMerge, mergeMethodSquash, mergeMethodRebase:
+		return nil
+	}
+	return fmt.Errorf("invalid merge method %q, expected %q, %q or %q", method, mergeMethodMerge, mergeMethodSquash, mergeMethodRebase)
+}
+
+// merger is implemented by backends that can merge pull requests
+type merger interface {
+	// mergePullRequest merges pr with the given method
+	mergePullRequest(ctx context.Context, details Repo, pr *pullRequest, method string) error
+}
+
+// branchDeleter is implemented by backends that can delete branches
+type branchDeleter interface {
+	// deleteBranch deletes branch from the repository of details
+	deleteBranch(ctx context.Context, details Repo, branch string) error
+}
+
+// mergeOptions are the settings of the merge command
+type mergeOptions struct {
+	method       string
+	deleteBranch bool
+	dryRun       bool
+}
+
+// mergeBlockers returns why a pull request must not be merged yet, or nothing when it is ready:
+// it must be open and not a draft, have passing (or no) checks, no missing approvals and no conflicts
+func mergeBlockers(status *pullRequestStatus) []string {
+	var blockers []string
+	if status.State != prStateOpen {
+		blockers = append(blockers, "PR is "+status.State)
+	}
+	switch status.Checks {
+	case checksPending:
+		blockers = append(blockers, "checks are pending")
+	case checksFailure:
+		blockers = append(blockers, "checks failed")
+	}
+	switch status.ReviewDecision {
+	case reviewChangesRequested:
+		blockers = append(blockers, "changes were requested")
+	case reviewRequired:
+		blockers = append(blockers, "approval is required")
+	}
+	if status.Mergeable == "conflicting" {
+		blockers = append(blockers, "PR has conflicts")
+	}
+	return blockers
+}
+
+func runMerge(args []string) error {
+	fs := newCommandFlagSet("merge", "Merge the PR of every entry once its checks pass and it is approved.\nEntries are merged in groups by `merge_order`, lowest first; a group only starts when the previous one was fully merged.")
+	flags := addCampaignFlags(fs)
+	method := fs.String("method", mergeMethodMerge, "Merge method: merge, squash or rebase")
+	deleteBranch := fs.Bool("delete-branch", false, "Delete the head branch after merging")
+	dryRun := fs.Bool("dry-run", false, "Check which PRs are ready without merging them")
+	config, err := loadCampaign(fs, flags, args)
+	if err != nil {
+		return err
+	}
+	return mergePullRequests(config, mergeOptions{method: *method, deleteBranch: *deleteBranch, dryRun: *dryRun})
+}
+
+// mergePullRequests merges the pull request of every entry that is ready
+func mergePullRequests(config *Config, opts mergeOptions) error {
+	if err := validateMergeMethod(opts.method); err != nil {
+		return err
+	}
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return err
+	}
+
+	retry := newRetrier(config)
+	stdout := progressOutput(config.Output)
+	rep := newReporter(os.Stdout, config.Output)
+
+	mergeEntry := func(repoName string, details Repo) result {
+		res := newResult(repoName, details)
+		ctx := context.Background()
+
+		var status *pullRequestStatus
+		err := retry.do(ctx, "Looking up PR status for "+repoName, func(ctx context.Context) (err error) {
+			status, err = lookupStatus(ctx, config, details)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to look up PR status for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to look up PR status for %s: %w", repoName, err))
+		}
+		if status == nil {
+			return res.finish(statusNotFound, fmt.Errorf("no PR found for %s", repoName))
+		}
+		res.Number, res.URL = status.Number, status.URL
+		res.ReviewDecision, res.Checks, res.Mergeable = status.ReviewDecision, status.Checks, status.Mergeable
+
+		if status.State == prStateMerged {
+			fmt.Fprintf(stdout, "PR for %s is already merged (%s), skipping\n", repoName, status.URL)
+			return res.finish(statusSkipped, nil)
+		}
+		if blockers := mergeBlockers(status); len(blockers) > 0 {
+			fmt.Fprintf(stdout, "Not merging PR for %s (%s): %s\n", repoName, status.URL, strings.Join(blockers, ", "))
+			return res.finish(statusBlocked, fmt.Errorf("PR for %s is not ready to merge: %s", repoName, strings.Join(blockers, ", ")))
+		}
+
+		if opts.dryRun {
+			fmt.Fprintf(stdout, "DRY RUN: Would merge PR for %s (%s) with method %s\n", repoName, status.URL, opts.method)
+			return res.finish(statusDryRun, nil)
+		}
+
+		be, err := backendWith[merger](config, details, "merge")
+		if err != nil {
+			return res.finish(statusFailed, fmt.Errorf("%s: %w", repoName, err))
+		}
+		err = retry.do(ctx, "Merging PR for "+repoName, func(ctx context.Context) error {
+			return be.mergePullRequest(ctx, details, &status.pullRequest, opts.method)
+		})
+		if err != nil {
+			log.Printf("Failed to merge PR for %s: %v\n", repoName, err)
+			return res.finish(statusFailed, fmt.Errorf("failed to merge PR for %s: %w", repoName, err))
+		}
+		fmt.Fprintf(stdout, "PR merged for %s (%s)\n", repoName, status.URL)
+
+		if opts.deleteBranch {
+			if err := deleteHeadBranch(ctx, config, retry, details); err != nil {
+				log.Printf("Warning: PR for %s was merged but %v\n", repoName, err)
+				return res.finish(statusMerged, fmt.Errorf("PR was merged but %w", err))
+			}
+		}
+		return res.finish(statusMerged, nil)
+	}
+
+	// Groups by merge_order run one after the other; a group that did not fully merge stops the rest
+	var incomplete string
+	for _, group := range mergeGroups(repoNames, prepared) {
+		if incomplete != "" {
+			for _, repoName := range group {
+				res := newResult(repoName, prepared[repoName])
+				rep.add(res.finish(statusSkipped, fmt.Errorf("not attempted because %s was not merged", incomplete)))
+			}
+			continue
+		}
+
+		var results []result
+		resultChan := make(chan result, len(group))
+		forEachRepo(config.Concurrency, group, prepared, func(repoName string, details Repo) {
+			res := mergeEntry(repoName, details)
+			rep.add(res)
+			resultChan <- res
+		})
+		close(resultChan)
+		for res := range resultChan {
+			results = append(results, res)
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+		for _, res := range results {
+			if res.Status != statusMerged && res.Status != statusSkipped && res.Status != statusDryRun {
+				incomplete = res.Key
+				break
+			}
+		}
+	}
+
+	if err := rep.finish(); err != nil {
+		log.Printf("Failed to write report: %v\n", err)
+	}
+
+	var notMerged []result
+	for _, res := range rep.results {
+		if res.err != nil && res.Status != statusMerged {
+			notMerged = append(notMerged, res)
+		}
+	}
+	if len(notMerged) > 0 {
+		sort.Slice(notMerged, func(i, j int) bool { return notMerged[i].Key < notMerged[j].Key })
+		log.Printf("%d of %d pull requests were not merged:\n", len(notMerged), len(prepared))
+		for _, res := range notMerged {
+			log.Printf("  %s [%s] %s\n", res.Key, res.Status, res.Error)
+		}
+		return fmt.Errorf("one or more pull requests were not merged (first error: %w)", notMerged[0].err)
+	}
+	return nil
+}
+
+// mergeGroups splits the prepared entries into groups of equal merge_order, lowest first
+func mergeGroups(repoNames []string, prepared map[string]Repo) [][]string {
+	byOrder := map[int][]string{}
+	var orders []int
+	for _, repoName := range repoNames {
+		details, ok := prepared[repoName]
+		if !ok {
+			continue
+		}
+		if _, seen := byOrder[details.MergeOrder]; !seen {
+			orders = append(orders, details.MergeOrder)
+		}
+		byOrder[details.MergeOrder] = append(byOrder[details.MergeOrder], repoName)
+	}
+	sort.Ints(orders)
+
+	groups := make([][]string, 0, len(orders))
+	for _, order := range orders {
+		groups = append(groups, byOrder[order])
+	}
+	return groups
+}
+
+// deleteHeadBranch deletes the head branch of details
+func deleteHeadBranch(ctx context.Context, config *Config, retry retrier, details Repo) error {
+	be, err := backendWith[branchDeleter](config, details, "deleting branches")
+	if err != nil {
+		return err
+	}
+	err = retry.do(ctx, "Deleting branch "+details.Head+" of "+details.Repo, func(ctx context.Context) error {
+		return be.deleteBranch(ctx, details, details.Head)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", details.Head, err)
+	}
+	return nil
+}
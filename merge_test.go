@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMergeBlockers(t *testing.T) {
+	ready := pullRequestStatus{State: prStateOpen, ReviewDecision: reviewApproved, Checks: checksSuccess, Mergeable: "mergeable"}
+	if blockers := mergeBlockers(&ready); len(blockers) != 0 {
+		t.Errorf("Expected no blockers for a ready PR, got %v", blockers)
+	}
+	noReviewsNoChecks := pullRequestStatus{State: prStateOpen, Checks: checksNone, Mergeable: "unknown"}
+	if blockers := mergeBlockers(&noReviewsNoChecks); len(blockers) != 0 {
+		t.Errorf("Expected no blockers without required reviews or checks, got %v", blockers)
+	}
+
+	blocked := pullRequestStatus{State: prStateDraft, ReviewDecision: reviewRequired, Checks: checksPending, Mergeable: "conflicting"}
+	got := strings.Join(mergeBlockers(&blocked), ", ")
+	want := "PR is draft, checks are pending, approval is required, PR has conflicts"
+	if got != want {
+		t.Errorf("mergeBlockers = %q, want %q", got, want)
+	}
+}
+
+func TestMergePullRequests(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/lib", Base: "main", Head: "dev"}).Checks = checksSuccess
+	be.add(Repo{Repo: "org/done", Base: "main", Head: "dev"}).Merged = true
+	be.add(Repo{Repo: "org/svc", Base: "main", Head: "dev"}).Review = reviewApproved
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+		"lib":  {Repo: "org/lib", Base: "main", Head: "dev"},
+		"done": {Repo: "org/done", Base: "main", Head: "dev"},
+		"svc":  {Repo: "org/svc", Base: "main", Head: "dev", MergeOrder: 1},
+	}}
+	var err error
+	captureStdout(t, func() {
+		err = mergePullRequests(config, mergeOptions{method: mergeMethodSquash, deleteBranch: true})
+	})
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	if be.mergedBy[1] != mergeMethodSquash || be.mergedBy[3] != mergeMethodSquash {
+		t.Errorf("Expected lib and svc to be squash merged, got %v", be.mergedBy)
+	}
+	if _, ok := be.mergedBy[2]; ok {
+		t.Error("Expected the already merged PR not to be merged again")
+	}
+	if strings.Join(be.deleted, " ") != "org/lib:dev org/svc:dev" {
+		t.Errorf("Expected head branches of merged PRs to be deleted, got %v", be.deleted)
+	}
+}
+
+func TestMergePullRequestsStopsAtBlockedGroup(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/lib", Base: "main", Head: "dev"}).Checks = checksFailure
+	be.add(Repo{Repo: "org/svc", Base: "main", Head: "dev"})
+
+	config := &Config{Output: outputJSON, Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+		"lib":     {Repo: "org/lib", Base: "main", Head: "dev"},
+		"svc":     {Repo: "org/svc", Base: "main", Head: "dev", MergeOrder: 1},
+		"missing": {Repo: "org/missing", Base: "main", Head: "dev", MergeOrder: 1},
+	}}
+	var err error
+	output := captureStdout(t, func() {
+		err = mergePullRequests(config, mergeOptions{method: mergeMethodMerge})
+	})
+	if err == nil || !strings.Contains(err.Error(), "checks failed") {
+		t.Fatalf("Expected blocked merge to be reported, got %v", err)
+	}
+	if len(be.mergedBy) != 0 {
+		t.Errorf("Expected nothing to be merged, got %v", be.mergedBy)
+	}
+	if !strings.Contains(output, `"status": "blocked"`) || strings.Count(output, "not attempted because lib was not merged") != 2 {
+		t.Errorf("Expected the later group not to be attempted, got:\n%s", output)
+	}
+}
+
+func TestMergePullRequestsDryRun(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/r", Base: "main", Head: "dev"})
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{"r": {Repo: "org/r", Base: "main", Head: "dev"}}}
+	output := captureStdout(t, func() {
+		if err := mergePullRequests(config, mergeOptions{method: mergeMethodRebase, dryRun: true}); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "DRY RUN: Would merge PR for r (https://forge.test/org/r/pull/1) with method rebase") {
+		t.Errorf("Unexpected dry run output: %q", output)
+	}
+	if len(be.mergedBy) != 0 {
+		t.Errorf("Expected nothing to be merged in a dry run, got %v", be.mergedBy)
+	}
+
+	if err := mergePullRequests(config, mergeOptions{method: "fast-forward"}); err == nil {
+		t.Error("Expected an error for an invalid merge method")
+	}
+}
+
+func TestForgeBackendsMergePullRequest(t *testing.T) {
+	ctx := context.Background()
+	pr := &pullRequest{Number: 3}
+
+	gitlab, gitlabServer := newFakeGitLab(t)
+	gitlabBackend := newGitLabBackend(gitlabServer.URL+"/api/v4", "secret")
+	if err := gitlabBackend.mergePullRequest(ctx, Repo{Repo: "group/sub/svc"}, pr, mergeMethodSquash); err != nil {
+		t.Fatalf("GitLab merge failed: %v", err)
+	}
+	if body := gitlab.bodies["PUT /api/v4/projects/group%2Fsub%2Fsvc/merge_requests/3/merge"]; body["squash"] != true {
+		t.Errorf("Expected a squash merge on GitLab, got %v (requests: %v)", body, gitlab.requests)
+	}
+	if err := gitlabBackend.mergePullRequest(ctx, Repo{Repo: "group/sub/svc"}, pr, mergeMethodRebase); classOf(err) != classValidation {
+		t.Errorf("Expected rebase to be rejected on GitLab, got %v", err)
+	}
+
+	gitea, giteaServer := newFakeGitea(t)
+	if err := newGiteaBackend(giteaServer.URL+"/api/v1", "secret").mergePullRequest(ctx, Repo{Repo: "org/r"}, pr, mergeMethodRebase); err != nil {
+		t.Fatalf("Gitea merge failed: %v", err)
+	}
+	if body := gitea.bodies["POST /api/v1/repos/org/r/pulls/3/merge"]; body["Do"] != mergeMethodRebase {
+		t.Errorf("Expected a rebase merge on Gitea, got %v (requests: %v)", body, gitea.requests)
+	}
+
+	bitbucket, bitbucketServer := newFakeBitbucket(t)
+	if err := newBitbucketBackend(bitbucketServer.URL+"/rest/api/1.0", "secret").mergePullRequest(ctx, Repo{Repo: "PRJ/svc"}, pr, mergeMethodMerge); err != nil {
+		t.Fatalf("Bitbucket merge failed: %v", err)
+	}
+	if last := bitbucket.requests[len(bitbucket.requests)-1]; last != "POST /rest/api/1.0/projects/PRJ/repos/svc/pull-requests/3/merge?version=5" {
+		t.Errorf("Expected a merge with the current version, got %s", last)
+	}
+	if body := bitbucket.bodies["POST /rest/api/1.0/projects/PRJ/repos/svc/pull-requests/3/merge"]; body["strategyId"] != "no-ff" {
+		t.Errorf("Expected the no-ff strategy on Bitbucket, got %v", body)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// preflighter is implemented by backends that can check an entry before anything is created
+type preflighter interface {
+	// preflight returns the problems that would make creating the pull request of details fail.
+	// The head branch is only checked with checkHead, since it may be created by bulkpr itself.
+	preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error)
+}
+
+// githubGetter fetches a GitHub REST API path into out, failing with classNotFound for missing resources
+type githubGetter func(ctx context.Context, path string, out interface{}) error
+
+// githubPreflight checks an entry against the GitHub REST API: the repository exists, is not archived
+// and can be pushed to, the branches exist and head is ahead of base, and labels, assignees and reviewers exist
+func githubPreflight(ctx context.Context, get githubGetter, details Repo, checkHead bool) ([]string, error) {
+	repoPath := "repos/" + details.Repo
+	var repo struct {
+		Archived    bool `json:"archived"`
+		Permissions *struct {
+			Push bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := get(ctx, repoPath, &repo); err != nil {
+		if classOf(err) == classNotFound {
+			return []string{fmt.Sprintf("repository %s does not exist or is not accessible", details.Repo)}, nil
+		}
+		return nil, err
+	}
+
+	var problems []string
+	// missing runs a lookup and records problem if the resource does not exist
+	missing := func(path, problem string) error {
+		return checkExists(&problems, get(ctx, path, nil), problem)
+	}
+
+	// A head given as "owner:branch" is in a fork, which is what is pushed to instead of the repository
+	_, _, fork := strings.Cut(details.Head, ":")
+	if repo.Archived {
+		problems = append(problems, fmt.Sprintf("repository %s is archived", details.Repo))
+	}
+	if repo.Permissions != nil && !repo.Permissions.Push && !fork {
+		problems = append(problems, fmt.Sprintf("you cannot push to %s", details.Repo))
+	}
+
+	before := len(problems)
+	if err := missing(repoPath+"/branches/"+url.PathEscape(details.Base), fmt.Sprintf("branch %s does not exist", details.Base)); err != nil {
+		return nil, err
+	}
+	if checkHead && len(problems) == before {
+		// The comparison finds the head in the fork too, whatever the fork is called
+		var compare struct {
+			AheadBy int `json:"ahead_by"`
+		}
+		err := get(ctx, repoPath+"/compare/"+url.PathEscape(details.Base)+"..."+url.PathEscape(details.Head), &compare)
+		switch {
+		case classOf(err) == classNotFound:
+			problems = append(problems, fmt.Sprintf("branch %s does not exist", details.Head))
+		case err != nil:
+			return nil, err
+		case compare.AheadBy == 0:
+			problems = append(problems, fmt.Sprintf("%s has no commits ahead of %s", details.Head, details.Base))
+		}
+	}
+
+	for _, label := range details.Labels {
+		if err := missing(repoPath+"/labels/"+url.PathEscape(label), fmt.Sprintf("label %q does not exist", label)); err != nil {
+			return nil, err
+		}
+	}
+	for _, assignee := range details.Assignees {
+		if assignee == "@me" {
+			continue
+		}
+		if err := missing(repoPath+"/assignees/"+url.PathEscape(assignee), fmt.Sprintf("%s cannot be assigned", assignee)); err != nil {
+			return nil, err
+		}
+	}
+	for _, reviewer := range details.Reviewers {
+		if org, team, ok := strings.Cut(reviewer, "/"); ok {
+			if err := missing("orgs/"+url.PathEscape(org)+"/teams/"+url.PathEscape(team), fmt.Sprintf("team %s does not exist", reviewer)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := missing(repoPath+"/collaborators/"+url.PathEscape(reviewer), fmt.Sprintf("%s cannot review pull requests in %s", reviewer, details.Repo)); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+// checkExists returns the error of a lookup, except for a missing resource, which is recorded as problem
+func checkExists(problems *[]string, err error, problem string) error {
+	if classOf(err) == classNotFound {
+		*problems = append(*problems, problem)
+		return nil
+	}
+	return err
+}
+
+func (b ghBackend) preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error) {
+	return githubPreflight(ctx, func(ctx context.Context, path string, out interface{}) error {
+		data, err := runCommandOutput(ctx, b.api(path)...)
+		if err != nil || out == nil || len(data) == 0 {
+			return err
+		}
+		return json.Unmarshal(data, out)
+	}, details, checkHead)
+}
+
+func (b *githubBackend) preflight(ctx context.Context, details Repo, checkHead bool) ([]string, error) {
+	return githubPreflight(ctx, func(ctx context.Context, path string, out interface{}) error {
+		return b.client.do(ctx, "GET", "/"+path, nil, out)
+	}, details, checkHead)
+}
+
+// preflightEnabled reports whether the preflight checks run before a campaign, which they do unless disabled
+func (c *Config) preflightEnabled() bool {
+	return c.Preflight == nil || *c.Preflight
+}
+
+// preflightEntries returns the prepared entries a campaign will act on, which are the ones to check:
+// entries done in a previous run are left out when resuming, and so are entries with an open PR
+// under the skip policy
+func preflightEntries(ctx context.Context, config *Config, state *campaignState, onExisting string, retry retrier, repoNames []string, prepared map[string]Repo) map[string]Repo {
+	var mu sync.Mutex
+	entries := map[string]Repo{}
+	forEachRepo(config.Concurrency, repoNames, prepared, func(repoName string, details Repo) {
+		if _, ok := resumedEntry(config, state, repoName, details); ok {
+			return
+		}
+		if onExisting == existingSkip {
+			// Lookup errors are left to the processing of the entry, which reports them
+			if be, err := backendFor(config, details); err == nil {
+				var existing *pullRequest
+				err := retry.do(ctx, "Looking up existing PR for "+repoName, func(ctx context.Context) (err error) {
+					existing, err = be.findPullRequest(ctx, details)
+					return err
+				})
+				if err == nil && existing != nil {
+					return
+				}
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		entries[repoName] = details
+	})
+	return entries
+}
+
+// runPreflight checks every prepared entry before anything is created and returns an error per entry
+// that would fail, and the entries whose backend cannot run the checks, which are skipped with a warning.
+func runPreflight(config *Config, repoNames []string, prepared map[string]Repo) (map[string]error, []string) {
+	retry := newRetrier(config)
+	stdout := progressOutput(config.Output)
+
+	var mu sync.Mutex
+	failed := map[string]error{}
+	var unchecked []string
+	unsupported := map[string]bool{}
+	forEachRepo(config.Concurrency, repoNames, prepared, func(repoName string, details Repo) {
+		var problems []string
+		checker, err := backendWith[preflighter](config, details, "preflight checks")
+		var unsupportedErr *unsupportedOperationError
+		if errors.As(err, &unsupportedErr) {
+			mu.Lock()
+			defer mu.Unlock()
+			unchecked = append(unchecked, repoName)
+			if !unsupported[unsupportedErr.provider] {
+				unsupported[unsupportedErr.provider] = true
+				log.Printf("Warning: %v, skipping them\n", err)
+			}
+			return
+		}
+		if err == nil {
+			checkHead := !hasChange(details) && config.Workspace == ""
+			err = retry.do(context.Background(), "Checking "+repoName, func(ctx context.Context) (err error) {
+				problems, err = checker.preflight(ctx, details, checkHead)
+				return err
+			})
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("could not be checked: %v", err))
+		}
+		if len(problems) == 0 {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		failed[repoName] = withClass(classValidation, fmt.Errorf("preflight checks failed for %s: %s", repoName, strings.Join(problems, "; ")))
+	})
+
+	keys := make([]string, 0, len(failed))
+	for key := range failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		log.Printf("  %v\n", failed[key])
+	}
+	sort.Strings(unchecked)
+	fmt.Fprintf(stdout, "Preflight checks: %d of %d entries passed\n", len(prepared)-len(failed)-len(unchecked), len(prepared)-len(unchecked))
+	return failed, unchecked
+}
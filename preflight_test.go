@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitHubBackendPreflight(t *testing.T) {
+	existing := map[string]string{
+		"/repos/org/r":                           `{"archived": true, "permissions": {"push": false}}`,
+		"/repos/org/r/branches/main":             `{}`,
+		"/repos/org/r/branches/feature":          `{}`,
+		"/repos/org/r/compare/main...feature":    `{"ahead_by": 0}`,
+		"/repos/org/r/compare/main...alice:feat": `{"ahead_by": 2}`,
+		"/repos/org/r/labels/bug":                `{}`,
+		"/repos/org/r/assignees/alice":           ``,
+		"/repos/org/r/collaborators/bob":         ``,
+		"/orgs/org/teams/platform":               `{}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := existing[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+			return
+		}
+		if body == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+	be := newGitHubBackend(server.URL, "")
+	ctx := context.Background()
+
+	details := Repo{Repo: "org/r", Base: "main", Head: "feature",
+		Labels: []string{"bug", "wontfix"}, Assignees: []string{"alice", "@me", "carol"}, Reviewers: []string{"bob", "org/platform", "org/ghosts", "dave"}}
+	problems, err := be.preflight(ctx, details, true)
+	if err != nil {
+		t.Fatalf("preflight failed: %v", err)
+	}
+	want := []string{
+		"repository org/r is archived",
+		"you cannot push to org/r",
+		"feature has no commits ahead of main",
+		`label "wontfix" does not exist`,
+		"carol cannot be assigned",
+		"team org/ghosts does not exist",
+		"dave cannot review pull requests in org/r",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(problems, "\n"))
+	}
+
+	// A missing head is reported once, without comparing it, and not at all when bulkpr creates it
+	details = Repo{Repo: "org/r", Base: "main", Head: "missing"}
+	if problems, _ := be.preflight(ctx, details, true); len(problems) != 3 || problems[2] != "branch missing does not exist" {
+		t.Errorf("Unexpected problems: %v", problems)
+	}
+	if problems, _ := be.preflight(ctx, details, false); len(problems) != 2 {
+		t.Errorf("Expected the head not to be checked, got %v", problems)
+	}
+
+	// A head in a fork is looked up through the comparison, and is pushed to the fork instead of the repository
+	if problems, _ := be.preflight(ctx, Repo{Repo: "org/r", Base: "main", Head: "alice:feat"}, true); strings.Join(problems, ", ") != "repository org/r is archived" {
+		t.Errorf("Unexpected problems for a head in a fork: %v", problems)
+	}
+	if problems, _ := be.preflight(ctx, Repo{Repo: "org/r", Base: "main", Head: "alice:gone"}, true); len(problems) != 2 || problems[1] != "branch alice:gone does not exist" {
+		t.Errorf("Unexpected problems for a missing head in a fork: %v", problems)
+	}
+
+	if problems, err := be.preflight(ctx, Repo{Repo: "org/gone", Base: "main", Head: "x"}, true); err != nil || len(problems) != 1 || !strings.Contains(problems[0], "does not exist or is not accessible") {
+		t.Errorf("Unexpected result for a missing repository: %v, %v", problems, err)
+	}
+}
+
+// newPreflightServer serves the given bodies by request URI, and 404 for anything else
+func newPreflightServer(t *testing.T, existing map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := existing[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+			return
+		}
+		if body == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGitLabBackendPreflight(t *testing.T) {
+	server := newPreflightServer(t, map[string]string{
+		"/projects/group%2Fsvc":                                     `{"archived": false, "permissions": {"project_access": {"access_level": 20}, "group_access": {"access_level": 30}}}`,
+		"/projects/group%2Fro":                                      `{"archived": true, "permissions": {"project_access": {"access_level": 20}, "group_access": null}}`,
+		"/projects/group%2Fsvc/repository/branches/main":            `{}`,
+		"/projects/group%2Fsvc/repository/branches/dev":             `{}`,
+		"/projects/group%2Fsvc/repository/compare?from=main&to=dev": `{"commits": []}`,
+		"/projects/group%2Fsvc/labels/bug":                          `{}`,
+		"/users?username=alice":                                     `[{"id": 11}]`,
+		"/users?username=bob":                                       `[{"id": 12}]`,
+		"/projects/group%2Fsvc/members/all/11":                      `{}`,
+		"/projects/group%2Fro/repository/branches/main":             `{}`,
+	})
+	be := newGitLabBackend(server.URL, "")
+	ctx := context.Background()
+
+	details := Repo{Provider: providerGitLab, Repo: "group/svc", Base: "main", Head: "dev",
+		Labels: []string{"bug", "wontfix"}, Assignees: []string{"alice", "carol"}, Reviewers: []string{"bob", "group/team"}}
+	problems, err := be.preflight(ctx, details, true)
+	if err != nil {
+		t.Fatalf("preflight failed: %v", err)
+	}
+	want := []string{
+		`team reviewers not supported by provider "gitlab"`,
+		"dev has no commits ahead of main",
+		`label "wontfix" does not exist`,
+		"carol cannot be assigned",
+		"bob cannot review merge requests in group/svc",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(problems, "\n"))
+	}
+
+	problems, err = be.preflight(ctx, Repo{Provider: providerGitLab, Repo: "group/ro", Base: "main", Head: "dev"}, true)
+	if err != nil || strings.Join(problems, ", ") != "project group/ro is archived, you cannot push to group/ro, branch dev does not exist" {
+		t.Errorf("Unexpected result: %v, %v", problems, err)
+	}
+	if problems, err := be.preflight(ctx, Repo{Provider: providerGitLab, Repo: "group/gone", Base: "main", Head: "dev"}, true); err != nil || len(problems) != 1 || !strings.Contains(problems[0], "does not exist or is not accessible") {
+		t.Errorf("Unexpected result for a missing project: %v, %v", problems, err)
+	}
+}
+
+func TestGiteaBackendPreflight(t *testing.T) {
+	server := newPreflightServer(t, map[string]string{
+		"/repos/org/r":                           `{"archived": true, "permissions": {"push": false}}`,
+		"/repos/org/r/branches/main":             `{}`,
+		"/repos/org/r/compare/main...dev":        `{"total_commits": 0}`,
+		"/repos/org/r/compare/main...alice:feat": `{"total_commits": 1}`,
+		"/repos/org/r/labels?limit=200":          `[{"id": 1, "name": "bug"}]`,
+		"/repos/org/r/collaborators/alice":       ``,
+		"/repos/org/r/teams/platform":            `{}`,
+	})
+	be := newGiteaBackend(server.URL, "")
+	ctx := context.Background()
+
+	details := Repo{Provider: providerGitea, Repo: "org/r", Base: "main", Head: "dev",
+		Labels: []string{"bug", "wontfix"}, Assignees: []string{"alice", "carol"}, Reviewers: []string{"alice", "org/platform", "org/ghosts", "dave"}}
+	problems, err := be.preflight(ctx, details, true)
+	if err != nil {
+		t.Fatalf("preflight failed: %v", err)
+	}
+	want := []string{
+		"repository org/r is archived",
+		"you cannot push to org/r",
+		"dev has no commits ahead of main",
+		`label "wontfix" does not exist`,
+		"carol cannot be assigned",
+		"team org/ghosts has no access to org/r",
+		"dave cannot review pull requests in org/r",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(problems, "\n"))
+	}
+
+	if problems, _ := be.preflight(ctx, Repo{Repo: "org/r", Base: "main", Head: "alice:feat"}, true); strings.Join(problems, ", ") != "repository org/r is archived" {
+		t.Errorf("Unexpected problems for a head in a fork: %v", problems)
+	}
+	if problems, _ := be.preflight(ctx, Repo{Repo: "org/r", Base: "main", Head: "gone"}, true); len(problems) != 3 || problems[2] != "branch gone does not exist" {
+		t.Errorf("Unexpected problems for a missing head: %v", problems)
+	}
+}
+
+func TestBitbucketBackendPreflight(t *testing.T) {
+	server := newPreflightServer(t, map[string]string{
+		"/projects/PRJ/repos/svc":                                                                    `{"archived": true}`,
+		"/projects/PRJ/repos/svc/branches?filterText=main&limit=100":                                 `{"values": [{"displayId": "main-old"}, {"displayId": "main"}]}`,
+		"/projects/PRJ/repos/svc/branches?filterText=dev&limit=100":                                  `{"values": [{"displayId": "dev"}]}`,
+		"/projects/PRJ/repos/svc/branches?filterText=feature&limit=100":                              `{"values": [{"displayId": "feature-x"}]}`,
+		"/projects/PRJ/repos/svc/commits?limit=1&since=refs%2Fheads%2Fmain&until=refs%2Fheads%2Fdev": `{"values": [{}]}`,
+		"/users/alice": `{}`,
+	})
+	be := newBitbucketBackend(server.URL, "")
+	ctx := context.Background()
+
+	details := Repo{Provider: providerBitbucket, Repo: "PRJ/svc", Base: "main", Head: "dev",
+		Labels: []string{"bug"}, Reviewers: []string{"alice", "bob"}}
+	problems, err := be.preflight(ctx, details, true)
+	if err != nil {
+		t.Fatalf("preflight failed: %v", err)
+	}
+	want := []string{
+		`labels not supported by provider "bitbucket"`,
+		"repository PRJ/svc is archived",
+		"user bob does not exist",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected problems:\n%s", strings.Join(problems, "\n"))
+	}
+
+	// Branches are matched by their full name, not by the filter
+	if problems, _ := be.preflight(ctx, Repo{Repo: "PRJ/svc", Base: "main", Head: "feature"}, true); len(problems) != 2 || problems[1] != "branch feature does not exist" {
+		t.Errorf("Unexpected problems for a missing head: %v", problems)
+	}
+}
+
+func TestGHBackendPreflight(t *testing.T) {
+	originalMockRunCommandOutput := mockRunCommandOutput
+	defer func() { mockRunCommandOutput = originalMockRunCommandOutput }()
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		switch args[2] {
+		case "repos/org/r":
+			return []byte(`{"archived": false, "permissions": {"push": true}}`), nil
+		case "repos/org/r/compare/main...feature":
+			return []byte(`{"ahead_by": 2}`), nil
+		case "repos/org/r/branches/main", "repos/org/r/branches/feature":
+			return []byte(`{}`), nil
+		case "repos/org/r/labels/bug":
+			return nil, newCommandError(args, "gh: Server Error (HTTP 502)", errors.New("exit status 1"))
+		}
+		return nil, newCommandError(args, "gh: Not Found (HTTP 404)", errors.New("exit status 1"))
+	}
+
+	problems, err := ghBackend{}.preflight(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "feature", Reviewers: []string{"bob"}}, true)
+	if err != nil || len(problems) != 1 || problems[0] != "bob cannot review pull requests in org/r" {
+		t.Errorf("Unexpected result: %v, %v", problems, err)
+	}
+	if _, err := (ghBackend{}).preflight(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "feature", Labels: []string{"bug"}}, true); classOf(err) != classNetwork {
+		t.Errorf("Expected lookup errors to be returned, got %v", err)
+	}
+}
+
+func TestCreatePullRequestPreflight(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.problems = map[string][]string{"org/b": {"branch dev does not exist"}}
+	config := func() *Config {
+		return &Config{Repos: map[string]Repo{
+			"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "A"},
+			"b": {Repo: "org/b", Base: "main", Head: "dev", Title: "B"},
+		}}
+	}
+
+	aborted := config()
+	aborted.Output = outputJSON
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(aborted, false) })
+	if err == nil || !strings.Contains(err.Error(), "nothing was created") {
+		t.Errorf("Expected the preflight to abort, got %v", err)
+	}
+	if len(be.prs) != 0 {
+		t.Fatalf("Expected nothing to be created, got %v", be.calls)
+	}
+	var report struct{ Results []result }
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Expected a report of the aborted run: %v\n%s", err, output)
+	}
+	if len(report.Results) != 2 || report.Results[0].Status != statusSkipped || report.Results[1].Status != statusFailed ||
+		!strings.Contains(report.Results[1].Error, "branch dev does not exist") {
+		t.Errorf("Unexpected report: %s", output)
+	}
+
+	keepGoing := config()
+	keepGoing.KeepGoing = true
+	err = createPullRequest(keepGoing, false)
+	if err == nil || !strings.Contains(err.Error(), "branch dev does not exist") {
+		t.Errorf("Expected the failed entry to be reported, got %v", err)
+	}
+	if len(be.prs) != 1 || be.prs[0].Repo != "org/a" {
+		t.Errorf("Expected only the PR of a, got %v", be.calls)
+	}
+}
+
+func TestPreflightSkipsEntriesThatAreDone(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/open", Base: "main", Head: "dev"})
+	// Once done, the head branch of an entry may be merged or deleted
+	be.problems = map[string][]string{"org/done": {"branch dev does not exist"}, "org/open": {"dev has no commits ahead of main"}}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := Repo{Repo: "org/done", Base: "main", Head: "dev", Title: "T"}
+	if err := state.record(newResult("done", done).finish(statusCreated, nil), configHash(done)); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{State: statePath, Resume: true, OnExisting: existingSkip, Repos: map[string]Repo{
+		"done": done,
+		"open": {Repo: "org/open", Base: "main", Head: "dev", Title: "T"},
+		"new":  {Repo: "org/new", Base: "main", Head: "dev", Title: "T"},
+	}}
+	if err := createPullRequest(config, false); err != nil {
+		t.Fatalf("Expected done and skipped entries not to be checked, got %v", err)
+	}
+	var checked []string
+	for _, c := range be.calls {
+		if strings.HasPrefix(c, "preflight ") {
+			checked = append(checked, c)
+		}
+	}
+	if len(checked) != 1 || checked[0] != "preflight org/new" {
+		t.Errorf("Expected only org/new to be checked, got %v", checked)
+	}
+}
+
+func TestKeepGoingRequiresPreflight(t *testing.T) {
+	useMemoryBackend(t)
+	config := &Config{Preflight: new(bool), KeepGoing: true, Repos: map[string]Repo{"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "A"}}}
+	if err := createPullRequest(config, false); err == nil || !strings.Contains(err.Error(), "--keep-going requires the preflight checks") {
+		t.Errorf("Expected --keep-going to be rejected without preflight checks, got %v", err)
+	}
+}
+
+func TestValidateCampaign(t *testing.T) {
+	be := useMemoryBackend(t)
+	config := func() *Config {
+		return &Config{Repos: map[string]Repo{
+			"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "A"},
+			"b": {Repo: "org/b", Base: "main", Head: "dev", Title: "B"},
+		}}
+	}
+
+	if err := validateCampaign(config(), true); err != nil {
+		t.Errorf("Expected a valid campaign, got %v", err)
+	}
+	be.problems = map[string][]string{"org/b": {"label \"x\" does not exist"}}
+	if err := validateCampaign(config(), false); err != nil {
+		t.Errorf("Expected no remote checks without --remote, got %v", err)
+	}
+	if err := validateCampaign(config(), true); err == nil || !strings.Contains(err.Error(), "1 of 2 entries") {
+		t.Errorf("Expected a remote problem, got %v", err)
+	}
+
+	invalid := config()
+	invalid.Repos["c"] = Repo{Repo: "org/c", Title: "C"}
+	if err := validateCampaign(invalid, false); err == nil {
+		t.Error("Expected an error for an entry without base and head")
+	}
+	for _, c := range be.calls {
+		if !strings.HasPrefix(c, "preflight ") {
+			t.Errorf("Expected only preflight checks, got %s", c)
+		}
+	}
+}
+
+func TestValidateCampaignReportsUncheckedEntries(t *testing.T) {
+	be := newMemoryBackend()
+	originalMockBackend := mockBackend
+	t.Cleanup(func() { mockBackend = originalMockBackend })
+	// Embedding hides preflight, like the backends that do not implement it
+	mockBackend = struct{ backend }{be}
+
+	config := &Config{Repos: map[string]Repo{"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "A"}}}
+	if err := validateCampaign(config, false); err != nil {
+		t.Errorf("Expected a valid campaign without --remote, got %v", err)
+	}
+	if err := validateCampaign(config, true); err == nil || !strings.Contains(err.Error(), "1 of 1 entries") {
+		t.Errorf("Expected an entry that could not be checked to be a problem, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Output formats for the run report
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// Statuses of a single entry in the run report
+const (
+	statusCreated = "created"
+	statusUpdated = "updated"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+	statusInvalid = "invalid"
+	statusDryRun  = "dry_run"
+	// The pull request of an entry was not found, e.g. by the status command
+	statusNotFound = "not_found"
+)
+
+// result is the outcome of processing one repository entry
+type result struct {
+	Key        string     `json:"key"`
+	Repo       string     `json:"repo"`
+	Base       string     `json:"base"`
+	Head       string     `json:"head"`
+	Status     string     `json:"status"`
+	Number     int        `json:"number,omitempty"`
+	URL        string     `json:"url,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	ErrorClass errorClass `json:"error_class,omitempty"`
+	// Outcome of enabling auto-merge, when `auto_merge` is set
+	AutoMerge      string `json:"auto_merge,omitempty"`
+	AutoMergeError string `json:"auto_merge_error,omitempty"`
+	// Set by the status command
+	ReviewDecision string    `json:"review_decision,omitempty"`
+	Checks         string    `json:"checks,omitempty"`
+	Mergeable      string    `json:"mergeable,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	DurationMS     int64     `json:"duration_ms"`
+
+	err error // The underlying error, kept for the returned error and classification
+}
+
+// newResult starts a result for a repository entry
+func newResult(key string, details Repo) result {
+	return result{Key: key, Repo: details.Repo, Base: details.Base, Head: details.Head, StartedAt: time.Now()}
+}
+
+// finish records the final status of r and its duration
+func (r result) finish(status string, err error) result {
+	r.Status = status
+	r.DurationMS = time.Since(r.StartedAt).Milliseconds()
+	if err != nil {
+		r.err = err
+		r.Error = err.Error()
+		r.ErrorClass = classOf(err)
+	}
+	return r
+}
+
+// pullRequestURLPattern matches the URL gh prints after creating a pull request
+var pullRequestURLPattern = regexp.MustCompile(`https?://\S+/pull/(\d+)`)
+
+// parsePullRequestURL extracts the last pull request URL and its number from command output
+func parsePullRequestURL(output string) (string, int) {
+	matches := pullRequestURLPattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return "", 0
+	}
+	last := matches[len(matches)-1]
+	number, _ := strconv.Atoi(last[1])
+	return last[0], number
+}
+
+// validateOutputFormat checks the value of `output` / --output
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", outputText, outputJSON, outputNDJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q, expected %q, %q or %q", format, outputText, outputJSON, outputNDJSON)
+}
+
+// machineReadable reports whether format is a structured output format.
+// In that case human-readable progress goes to stderr so stdout stays parseable.
+func machineReadable(format string) bool {
+	return format == outputJSON || format == outputNDJSON
+}
+
+// progressOutput returns where human-readable progress should be written for format
+func progressOutput(format string) io.Writer {
+	if machineReadable(format) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// reportSummary counts the results of a run by status
+type reportSummary struct {
+	Total  int            `json:"total"`
+	Status map[string]int `json:"status"`
+}
+
+// reporter writes results in the configured format.
+// Both JSON and NDJSON are written once at the end, sorted by entry key, so that reports of the same campaign compare line by line.
+type reporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	format  string
+	results []result
+}
+
+func newReporter(w io.Writer, format string) *reporter {
+	return &reporter{w: w, format: format}
+}
+
+// add records a result
+func (r *reporter) add(res result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results = append(r.results, res)
+}
+
+// finish writes the complete report for the JSON and NDJSON formats
+func (r *reporter) finish() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !machineReadable(r.format) {
+		return nil
+	}
+	sort.SliceStable(r.results, func(i, j int) bool { return r.results[i].Key < r.results[j].Key })
+	if r.format == outputNDJSON {
+		enc := json.NewEncoder(r.w)
+		for _, res := range r.results {
+			if err := enc.Encode(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	summary := reportSummary{Total: len(r.results), Status: map[string]int{}}
+	for _, res := range r.results {
+		summary.Status[res.Status]++
+	}
+
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Results []result      `json:"results"`
+		Summary reportSummary `json:"summary"`
+	}{r.results, summary})
+}
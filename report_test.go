@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout runs fn and returns what it wrote to os.Stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	originalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() { os.Stdout = originalStdout; r.Close() }()
+
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	fn()
+	w.Close()
+	os.Stdout = originalStdout
+	return <-done
+}
+
+// mockCreateOutput makes `gh pr create` print a PR URL and fail for the repos in failing
+func mockCreateOutput(t *testing.T, failing map[string]bool) {
+	t.Helper()
+	originalMockRunCommandOutput := mockRunCommandOutput
+	t.Cleanup(func() { mockRunCommandOutput = originalMockRunCommandOutput })
+
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		if args[2] != "create" {
+			return nil, nil
+		}
+		repo := args[len(args)-1]
+		if failing[repo] {
+			return nil, newCommandError(args, "HTTP 404: Not Found", errors.New("exit status 1"))
+		}
+		return []byte("\nCreating pull request for dev into main in " + repo + "\n\nhttps://github.com/" + repo + "/pull/7\n"), nil
+	}
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	url, number := parsePullRequestURL("Warning: 1 uncommitted change\nhttps://github.example.com/org/r/pull/123\n")
+	if url != "https://github.example.com/org/r/pull/123" || number != 123 {
+		t.Errorf("Unexpected URL %q and number %d", url, number)
+	}
+
+	url, number = parsePullRequestURL("no url here")
+	if url != "" || number != 0 {
+		t.Errorf("Expected no URL, got %q and %d", url, number)
+	}
+}
+
+func TestCreatePullRequestJSONReport(t *testing.T) {
+	mockCreateOutput(t, map[string]bool{"org/broken": true})
+
+	config := &Config{Preflight: new(bool), Output: outputJSON, Retries: new(int), Repos: map[string]Repo{
+		"ok":      {Repo: "org/ok", Base: "main", Head: "dev", Title: "T", Body: "B"},
+		"broken":  {Repo: "org/broken", Base: "main", Head: "dev", Title: "T", Body: "B"},
+		"invalid": {Repo: "org/invalid", Title: "T", Body: "B"},
+	}}
+
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, false) })
+	if err == nil {
+		t.Fatal("Expected failure for org/broken")
+	}
+
+	var report struct {
+		Results []result      `json:"results"`
+		Summary reportSummary `json:"summary"`
+	}
+	if e := json.Unmarshal([]byte(output), &report); e != nil {
+		t.Fatalf("Expected stdout to be a JSON report, got %v:\n%s", e, output)
+	}
+
+	byKey := map[string]result{}
+	for _, res := range report.Results {
+		byKey[res.Key] = res
+	}
+	if ok := byKey["ok"]; ok.Status != statusCreated || ok.URL != "https://github.com/org/ok/pull/7" || ok.Number != 7 {
+		t.Errorf("Unexpected result for ok: %+v", ok)
+	}
+	if broken := byKey["broken"]; broken.Status != statusFailed || broken.ErrorClass != classNotFound || broken.Error == "" {
+		t.Errorf("Unexpected result for broken: %+v", broken)
+	}
+	if invalid := byKey["invalid"]; invalid.Status != statusInvalid {
+		t.Errorf("Unexpected result for invalid: %+v", invalid)
+	}
+	if report.Summary.Total != 3 || report.Summary.Status[statusFailed] != 1 {
+		t.Errorf("Unexpected summary: %+v", report.Summary)
+	}
+}
+
+func TestCreatePullRequestNDJSONReport(t *testing.T) {
+	mockCreateOutput(t, nil)
+
+	config := &Config{Preflight: new(bool), Output: outputNDJSON, Repos: map[string]Repo{
+		"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "T", Body: "B"},
+		"b": {Repo: "org/b", Base: "main", Head: "dev", Title: "T", Body: "B"},
+	}}
+
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, false) })
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		var res result
+		if e := json.Unmarshal(scanner.Bytes(), &res); e != nil {
+			t.Fatalf("Expected every stdout line to be JSON, got %q", scanner.Text())
+		}
+		if res.Status != statusCreated || res.URL == "" {
+			t.Errorf("Unexpected result: %+v", res)
+		}
+		keys = append(keys, res.Key)
+	}
+	if strings.Join(keys, " ") != "a b" {
+		t.Errorf("Expected 2 NDJSON lines sorted by key, got %v:\n%s", keys, output)
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	if err := validateOutputFormat("xml"); err == nil {
+		t.Error("Expected error for unknown output format")
+	}
+	if err := validateOutputFormat(outputNDJSON); err != nil {
+		t.Errorf("Expected ndjson to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Defaults used when the configuration does not set retries or timeouts
+const (
+	defaultRetries        = 3
+	defaultBackoff        = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultCommandTimeout = 2 * time.Minute
+)
+
+// retrier retries transient failures with exponential backoff and jitter,
+// and bounds every attempt with a timeout
+type retrier struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	timeout    time.Duration
+}
+
+// newRetrier builds a retrier from the configuration, falling back to the defaults
+func newRetrier(config *Config) retrier {
+	r := retrier{
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+		timeout:    defaultCommandTimeout,
+	}
+	if config.Retries != nil {
+		r.retries = *config.Retries
+	}
+	if config.Timeout > 0 {
+		r.timeout = config.Timeout
+	}
+	return r
+}
+
+// delay returns the backoff before the given retry attempt (starting at 1),
+// using "equal jitter": half the exponential bound plus a random duration up to the other half
+func (r retrier) delay(attempt int) time.Duration {
+	bound := r.backoff << (attempt - 1)
+	if bound <= 0 || bound > r.maxBackoff {
+		bound = r.maxBackoff
+	}
+	return bound/2 + time.Duration(rand.Int63n(int64(bound/2)+1))
+}
+
+// do runs fn until it succeeds, fails with a non-transient error or runs out of retries
+func (r retrier) do(ctx context.Context, what string, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, r.timeout)
+		}
+		err := fn(attemptCtx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+		class := classOf(err)
+		if !class.transient() || attempt >= r.retries || ctx.Err() != nil {
+			return err
+		}
+
+		wait := r.delay(attempt + 1)
+		var hinted interface{ retryAfter() time.Duration }
+		if errors.As(err, &hinted) && hinted.retryAfter() > wait {
+			wait = hinted.retryAfter()
+		}
+		log.Printf("%s failed (%s), retrying in %s (%d/%d)\n", what, class, wait.Round(time.Millisecond), attempt+1, r.retries)
+		sleep(wait)
+	}
+}
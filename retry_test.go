@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mockSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	originalSleep := sleep
+	t.Cleanup(func() { sleep = originalSleep })
+
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	return &slept
+}
+
+func TestRetrierRetriesTransientErrors(t *testing.T) {
+	slept := mockSleep(t)
+	r := retrier{retries: 3, backoff: time.Second, maxBackoff: 30 * time.Second}
+
+	attempts := 0
+	err := r.do(context.Background(), "test", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return newCommandError([]string{"gh"}, "API rate limit exceeded", errors.New("exit status 1"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if len(*slept) != 2 {
+		t.Fatalf("Expected 2 backoffs, got %v", *slept)
+	}
+	if (*slept)[0] < 500*time.Millisecond || (*slept)[0] > time.Second || (*slept)[1] < time.Second || (*slept)[1] > 2*time.Second {
+		t.Errorf("Expected exponential backoff with jitter, got %v", *slept)
+	}
+}
+
+func TestRetrierGivesUp(t *testing.T) {
+	mockSleep(t)
+	r := retrier{retries: 2, backoff: time.Second, maxBackoff: time.Second}
+
+	attempts := 0
+	err := r.do(context.Background(), "test", func(ctx context.Context) error {
+		attempts++
+		return newCommandError([]string{"gh"}, "connection reset by peer", errors.New("exit status 1"))
+	})
+	if classOf(err) != classNetwork {
+		t.Errorf("Expected network error, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 1 attempt plus 2 retries, got %d", attempts)
+	}
+}
+
+func TestRetrierDoesNotRetryPermanentErrors(t *testing.T) {
+	slept := mockSleep(t)
+	r := retrier{retries: 3, backoff: time.Second, maxBackoff: time.Second}
+
+	attempts := 0
+	r.do(context.Background(), "test", func(ctx context.Context) error {
+		attempts++
+		return newCommandError([]string{"gh"}, "HTTP 401: Bad credentials", errors.New("exit status 1"))
+	})
+	if attempts != 1 || len(*slept) != 0 {
+		t.Errorf("Expected a single attempt without backoff, got %d attempts and %v", attempts, *slept)
+	}
+}
+
+func TestRetrierTimeout(t *testing.T) {
+	mockSleep(t)
+	r := retrier{retries: 1, backoff: time.Millisecond, maxBackoff: time.Millisecond, timeout: 10 * time.Millisecond}
+
+	attempts := 0
+	err := r.do(context.Background(), "test", func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if classOf(err) != classTimeout {
+		t.Errorf("Expected timeout, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected timed out attempt to be retried once, got %d attempts", attempts)
+	}
+}
+
+func TestCreatePullRequestRetriesAndReportsClass(t *testing.T) {
+	mockSleep(t)
+	originalMockRunCommand := mockRunCommand
+	defer func() { mockRunCommand = originalMockRunCommand }()
+
+	createAttempts := 0
+	mockRunCommand = func(args ...string) error {
+		if args[2] != "create" {
+			return nil
+		}
+		createAttempts++
+		return newCommandError(args, "HTTP 429: secondary rate limit", errors.New("exit status 1"))
+	}
+
+	retries := 2
+	config := &Config{Preflight: new(bool), Retries: &retries, Repos: map[string]Repo{"r": {Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B"}}}
+	err := createPullRequest(config, false)
+	if err == nil || classOf(err) != classRateLimited {
+		t.Fatalf("Expected rate limited failure, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "secondary rate limit") {
+		t.Errorf("Expected captured stderr in error, got %v", err)
+	}
+	if createAttempts != 3 {
+		t.Errorf("Expected 3 create attempts, got %d", createAttempts)
+	}
+}
+
+// lostCreateBackend creates the first pull request but reports a timeout, like a response lost on the way back
+type lostCreateBackend struct {
+	*memoryBackend
+	lost bool
+}
+
+func (b *lostCreateBackend) createPullRequest(ctx context.Context, details Repo) (*pullRequest, error) {
+	pr, err := b.memoryBackend.createPullRequest(ctx, details)
+	if err == nil && !b.lost {
+		b.lost = true
+		return nil, withClass(classTimeout, errors.New("context deadline exceeded"))
+	}
+	return pr, err
+}
+
+func TestCreatePullRequestLooksUpPRAfterTimeout(t *testing.T) {
+	mockSleep(t)
+	be := useMemoryBackend(t)
+	mockBackend = &lostCreateBackend{memoryBackend: be}
+
+	config := &Config{Preflight: new(bool), Output: outputJSON, Repos: map[string]Repo{"r": {Repo: "org/r", Base: "main", Head: "dev", Title: "T", Body: "B"}}}
+	var err error
+	output := captureStdout(t, func() { err = createPullRequest(config, false) })
+	if err != nil {
+		t.Fatalf("Expected the PR created by the timed out attempt to be found, got %v", err)
+	}
+	if len(be.prs) != 1 || strings.Join(be.calls, ", ") != "find org/r, create org/r, find org/r" {
+		t.Errorf("Expected a lookup instead of a second create, got %d PRs and calls %v", len(be.prs), be.calls)
+	}
+	if !strings.Contains(output, `"status": "created"`) || !strings.Contains(output, "https://forge.test/org/r/pull/1") {
+		t.Errorf("Expected the found PR to be reported as created, got %s", output)
+	}
+}
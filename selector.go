@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// repository is a repository as listed by a forge
+type repository struct {
+	FullName      string // owner/name
+	Topics        []string
+	Language      string
+	Visibility    string // public, private or internal
+	Archived      bool
+	Fork          bool
+	DefaultBranch string
+}
+
+// repoLister is implemented by backends that can list repositories
+type repoLister interface {
+	// listRepositories returns every repository of an organization or user
+	listRepositories(ctx context.Context, owner string) ([]repository, error)
+}
+
+// repoSelector selects repositories of an owner by their properties.
+// Archived repositories and forks are excluded unless asked for.
+type repoSelector struct {
+	Owner      string   `yaml:"owner"`
+	Topics     []string `yaml:"topics,omitempty"`     // Repositories must have all of these topics
+	Language   string   `yaml:"language,omitempty"`   // Primary language, case-insensitive
+	Name       string   `yaml:"name,omitempty"`       // Regular expression the repository name must match
+	Visibility string   `yaml:"visibility,omitempty"` // public, private or internal
+	Archived   bool     `yaml:"archived,omitempty"`   // Include archived repositories
+	Forks      bool     `yaml:"forks,omitempty"`      // Include forks
+
+	pattern string // Glob the repository name must match, from `repo: owner/pattern`
+}
+
+// validate checks the selector before anything is listed
+func (s repoSelector) validate() error {
+	if s.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if _, err := regexp.Compile(s.Name); err != nil {
+		return fmt.Errorf("invalid name pattern: %w", err)
+	}
+	if _, err := path.Match(s.pattern, ""); err != nil {
+		return fmt.Errorf("invalid repository pattern %q: %w", s.pattern, err)
+	}
+	switch s.Visibility {
+	case "", "public", "private", "internal":
+		return nil
+	}
+	return fmt.Errorf("invalid visibility %q, expected \"public\", \"private\" or \"internal\"", s.Visibility)
+}
+
+// match reports whether r is selected
+func (s repoSelector) match(r repository) bool {
+	if (r.Archived && !s.Archived) || (r.Fork && !s.Forks) {
+		return false
+	}
+	if s.Language != "" && !strings.EqualFold(s.Language, r.Language) {
+		return false
+	}
+	if s.Visibility != "" && !strings.EqualFold(s.Visibility, r.Visibility) {
+		return false
+	}
+	_, name, _ := strings.Cut(r.FullName, "/")
+	if s.Name != "" {
+		if matched, _ := regexp.MatchString(s.Name, name); !matched {
+			return false
+		}
+	}
+	if s.pattern != "" {
+		if matched, _ := path.Match(s.pattern, name); !matched {
+			return false
+		}
+	}
+	for _, topic := range s.Topics {
+		found := false
+		for _, t := range r.Topics {
+			if strings.EqualFold(t, topic) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// selectRepositories lists the repositories of the selector's owner through lister and returns the matching ones
+func selectRepositories(ctx context.Context, lister repoLister, s repoSelector) ([]repository, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	all, err := lister.listRepositories(ctx, s.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories of %s: %w", s.Owner, err)
+	}
+
+	var selected []repository
+	for _, r := range all {
+		if s.match(r) {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
+
+// isRepoPattern reports whether a `repo:` value is a glob such as "my-org/*-service"
+func isRepoPattern(repo string) bool {
+	return strings.ContainsAny(repo, "*?[")
+}
+
+// entrySelector returns the selector of an entry that targets several repositories,
+// either through `selector:` or a glob in `repo:`, and nil for an entry with a single repository
+func entrySelector(details Repo) (*repoSelector, error) {
+	if details.Selector != nil {
+		return details.Selector, nil
+	}
+	if !isRepoPattern(details.Repo) {
+		return nil, nil
+	}
+	owner, pattern, ok := strings.Cut(details.Repo, "/")
+	if !ok || pattern == "" || isRepoPattern(owner) {
+		return nil, fmt.Errorf("invalid repository pattern %q, expected \"owner/pattern\" with wildcards only in the name", details.Repo)
+	}
+	return &repoSelector{Owner: owner, pattern: pattern}, nil
+}
+
+// expandSelectors replaces every entry with a selector or a repository glob by one entry per matching repository,
+// keyed "<entry key>/<repository name>". Expanded entries without a base use the repository's default branch.
+// Repositories that another entry already targets with the same head are left to that entry.
+func expandSelectors(config *Config) error {
+	keys := make([]string, 0, len(config.Repos))
+	selectors := map[string]*repoSelector{}
+	explicit := map[string]bool{} // repo and head of the entries with a single repository
+	for key, details := range config.Repos {
+		selector, err := entrySelector(details)
+		if err != nil {
+			return fmt.Errorf("repo %s: %w", key, err)
+		}
+		if selector == nil {
+			explicit[strings.ToLower(details.Repo)+"\x00"+details.Head] = true
+			continue
+		}
+		if err := selector.validate(); err != nil {
+			return fmt.Errorf("repo %s: invalid selector: %w", key, err)
+		}
+		keys = append(keys, key)
+		selectors[key] = selector
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	retry := newRetrier(config)
+	stdout := progressOutput(config.Output)
+	listed := map[string][]repository{} // Listings by forge and owner, shared by selectors of the same owner
+	for _, key := range keys {
+		details, selector := config.Repos[key], selectors[key]
+		lister, err := backendWith[repoLister](config, details, "repository selectors")
+		if err != nil {
+			return fmt.Errorf("repo %s: %w", key, err)
+		}
+
+		cacheKey := strings.Join([]string{providerName(details), details.Host, strings.ToLower(selector.Owner)}, "\x00")
+		all, ok := listed[cacheKey]
+		if !ok {
+			err = retry.do(context.Background(), "Listing repositories of "+selector.Owner, func(ctx context.Context) (err error) {
+				all, err = lister.listRepositories(ctx, selector.Owner)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("repo %s: failed to list repositories of %s: %w", key, selector.Owner, err)
+			}
+			listed[cacheKey] = all
+		}
+
+		delete(config.Repos, key)
+		var names []string
+		for _, r := range all {
+			if !selector.match(r) || explicit[strings.ToLower(r.FullName)+"\x00"+details.Head] {
+				continue
+			}
+			_, name, _ := strings.Cut(r.FullName, "/")
+			expandedKey := key + "/" + name
+			if _, exists := config.Repos[expandedKey]; exists {
+				return fmt.Errorf("repo %s: expanded entry %s conflicts with an existing entry", key, expandedKey)
+			}
+			expanded := details
+			expanded.Selector = nil
+			expanded.Repo = r.FullName
+			if expanded.Base == "" {
+				expanded.Base = r.DefaultBranch
+			}
+			config.Repos[expandedKey] = expanded
+			names = append(names, r.FullName)
+		}
+
+		if len(names) == 0 {
+			log.Printf("Warning: repo %s matched no repositories of %s\n", key, selector.Owner)
+			continue
+		}
+		sort.Strings(names)
+		fmt.Fprintf(stdout, "Resolved %s to %d repositories: %s\n", key, len(names), strings.Join(names, ", "))
+	}
+	return nil
+}
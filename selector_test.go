@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRepoSelectorMatch(t *testing.T) {
+	service := repository{FullName: "org/billing-service", Topics: []string{"go", "Service"}, Language: "Go", Visibility: "private"}
+	tests := []struct {
+		name     string
+		selector repoSelector
+		repo     repository
+		want     bool
+	}{
+		{"no filters", repoSelector{Owner: "org"}, service, true},
+		{"all topics", repoSelector{Owner: "org", Topics: []string{"service", "go"}}, service, true},
+		{"missing topic", repoSelector{Owner: "org", Topics: []string{"go", "frontend"}}, service, false},
+		{"language", repoSelector{Owner: "org", Language: "go"}, service, true},
+		{"other language", repoSelector{Owner: "org", Language: "Rust"}, service, false},
+		{"name", repoSelector{Owner: "org", Name: "-service$"}, service, true},
+		{"name excludes owner", repoSelector{Owner: "org", Name: "^org"}, service, false},
+		{"visibility", repoSelector{Owner: "org", Visibility: "public"}, service, false},
+		{"archived excluded", repoSelector{Owner: "org"}, repository{FullName: "org/old", Archived: true}, false},
+		{"archived included", repoSelector{Owner: "org", Archived: true}, repository{FullName: "org/old", Archived: true}, true},
+		{"fork excluded", repoSelector{Owner: "org"}, repository{FullName: "org/fork", Fork: true}, false},
+		{"fork included", repoSelector{Owner: "org", Forks: true}, repository{FullName: "org/fork", Fork: true}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.selector.match(tt.repo); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRepoSelectorValidate(t *testing.T) {
+	for _, s := range []repoSelector{{}, {Owner: "org", Name: "("}, {Owner: "org", Visibility: "secret"}} {
+		if err := s.validate(); err == nil {
+			t.Errorf("Expected an error for %+v", s)
+		}
+	}
+	if err := (repoSelector{Owner: "org", Name: "svc", Visibility: "internal"}).validate(); err != nil {
+		t.Errorf("Expected nil error, got %v", err)
+	}
+}
+
+func TestExpandSelectors(t *testing.T) {
+	be := useMemoryBackend(t)
+	be.repos = []repository{
+		{FullName: "org/billing-service", Topics: []string{"go"}, DefaultBranch: "main"},
+		{FullName: "org/search-service", Topics: []string{"java"}, DefaultBranch: "master"},
+		{FullName: "org/old-service", Topics: []string{"go"}, Archived: true},
+		{FullName: "org/forked-service", Topics: []string{"go"}, Fork: true},
+		{FullName: "org/website", Topics: []string{"go"}, DefaultBranch: "main"},
+	}
+
+	config := &Config{
+		Defaults: Repo{Head: "chore/update", Title: "Update {{ .Name }}"},
+		Repos: map[string]Repo{
+			"services": {Repo: "org/*-service"},
+			"go":       {Selector: &repoSelector{Owner: "org", Topics: []string{"go"}}, Base: "develop"},
+			"search":   {Repo: "org/search-service", Base: "release"},
+		},
+	}
+	var repoNames []string
+	var prepared map[string]Repo
+	output := captureStdout(t, func() {
+		var err error
+		if repoNames, prepared, err = prepareRepos(config); err != nil {
+			t.Fatalf("prepareRepos failed: %v", err)
+		}
+	})
+
+	want := "[go/billing-service go/website search services/billing-service]"
+	if fmt.Sprint(repoNames) != want {
+		t.Errorf("Expected %s, got %v", want, repoNames)
+	}
+	if got := prepared["services/billing-service"]; got.Repo != "org/billing-service" || got.Base != "main" || got.Title != "Update billing-service" {
+		t.Errorf("Unexpected expanded entry: %+v", got)
+	}
+	if got := prepared["go/website"]; got.Base != "develop" || got.Selector != nil {
+		t.Errorf("Expected the entry's base, got %+v", got)
+	}
+	if !strings.Contains(output, "Resolved services to 1 repositories: org/billing-service") {
+		t.Errorf("Expected the resolved set in the output, got %q", output)
+	}
+	if fmt.Sprint(be.calls) != "[list org]" {
+		t.Errorf("Expected a single listing, got %v", be.calls)
+	}
+
+	// Expanding again is a no-op
+	if _, _, err := prepareRepos(config); err != nil || len(config.Repos) != 4 {
+		t.Errorf("Expected the expanded entries to be kept, got %v, %v", config.Repos, err)
+	}
+}
+
+func TestExpandSelectorsErrors(t *testing.T) {
+	useMemoryBackend(t)
+	for _, repo := range []Repo{
+		{Repo: "org-*/api"},
+		{Repo: "org/[a-"},
+		{Repo: "*"},
+		{Selector: &repoSelector{Owner: "org", Visibility: "secret"}},
+	} {
+		config := &Config{Repos: map[string]Repo{"x": repo}}
+		if err := expandSelectors(config); err == nil {
+			t.Errorf("Expected an error for %+v", repo)
+		}
+	}
+
+	mockBackend = nil
+	config := &Config{Repos: map[string]Repo{"x": {Repo: "group/*", Provider: providerGitLab}}}
+	if err := expandSelectors(config); classOf(err) != classValidation {
+		t.Errorf("Expected a validation error for GitLab, got %v", err)
+	}
+}
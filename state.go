@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateVersion is bumped whenever the state file format changes incompatibly
+const stateVersion = 1
+
+// stateEntry is what a campaign remembers about one repository entry
+type stateEntry struct {
+	Repo       string     `json:"repo"`
+	Base       string     `json:"base"`
+	Head       string     `json:"head"`
+	Status     string     `json:"status"`
+	Number     int        `json:"number,omitempty"`
+	URL        string     `json:"url,omitempty"`
+	ConfigHash string     `json:"config_hash"`
+	Error      string     `json:"error,omitempty"`
+	ErrorClass errorClass `json:"error_class,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// done reports whether the entry reached a final, successful state
+func (e stateEntry) done() bool {
+	return e.Status == statusCreated || e.Status == statusUpdated || e.Status == statusSkipped
+}
+
+// campaignState is the persistent state file of a campaign, keyed by config repo key
+type campaignState struct {
+	mu      sync.Mutex
+	path    string
+	Version int                   `json:"version"`
+	Entries map[string]stateEntry `json:"entries"`
+}
+
+// loadState reads the state file at path; a missing file yields an empty state
+func loadState(path string) (*campaignState, error) {
+	state := &campaignState{path: path, Version: stateVersion, Entries: map[string]stateEntry{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d in %s", state.Version, path)
+	}
+	if state.Entries == nil {
+		state.Entries = map[string]stateEntry{}
+	}
+	return state, nil
+}
+
+// lookup returns the recorded entry for key
+func (s *campaignState) lookup(key string) (stateEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.Entries[key]
+	return entry, ok
+}
+
+// resumedEntry returns the state of an entry a previous run completed with the same configuration,
+// when the campaign is resumed
+func resumedEntry(config *Config, state *campaignState, repoName string, details Repo) (stateEntry, bool) {
+	if !config.Resume || state == nil {
+		return stateEntry{}, false
+	}
+	entry, ok := state.lookup(repoName)
+	return entry, ok && entry.done() && entry.ConfigHash == configHash(details)
+}
+
+// ownsHead reports whether a previous run recorded key with the repository and head branch of details,
+// so that the branch was pushed by the campaign. Entries that failed on an existing branch do not own it.
+func (s *campaignState) ownsHead(key string, details Repo) bool {
+	if s == nil {
+		return false
+	}
+	entry, ok := s.lookup(key)
+	return ok && entry.Repo == details.Repo && entry.Head == details.Head && entry.ErrorClass != classAlreadyExists
+}
+
+// record stores the outcome of res and writes the state file
+func (s *campaignState) record(res result, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := stateEntry{
+		Repo:       res.Repo,
+		Base:       res.Base,
+		Head:       res.Head,
+		Status:     res.Status,
+		Number:     res.Number,
+		URL:        res.URL,
+		ConfigHash: hash,
+		Error:      res.Error,
+		ErrorClass: res.ErrorClass,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	// Keep the PR reference from an earlier run if this run could not determine it
+	if previous, ok := s.Entries[res.Key]; ok && entry.URL == "" && previous.Head == entry.Head && previous.Repo == entry.Repo {
+		entry.Number, entry.URL = previous.Number, previous.URL
+	}
+	s.Entries[res.Key] = entry
+
+	return s.save()
+}
+
+// save writes the state atomically so an interrupted run never leaves a truncated file
+func (s *campaignState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// configHash fingerprints the rendered fields of an entry that end up in the pull request
+func configHash(details Repo) string {
+	data, _ := json.Marshal(struct {
+		Repo, Base, Head, Title, Body string
+		Labels, Assignees, Reviewers  []string
+		Draft                         bool
+		// Omitted when empty so that hashes of entries without a script or edits stay the same
+		Script string     `json:",omitempty"`
+		Edits  []fileEdit `json:",omitempty"`
+	}{details.Repo, details.Base, details.Head, details.Title, details.Body, details.Labels, details.Assignees, details.Reviewers, details.Draft, details.Script, details.Edits})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
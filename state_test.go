@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadStateMissingFile(t *testing.T) {
+	state, err := loadState(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Expected empty state for missing file, got %v", err)
+	}
+	if len(state.Entries) != 0 {
+		t.Errorf("Expected no entries, got %v", state.Entries)
+	}
+}
+
+func TestStateRecordRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, _ := loadState(path)
+
+	res := result{Key: "k", Repo: "org/r", Base: "main", Head: "dev", Status: statusCreated, Number: 5, URL: "https://github.com/org/r/pull/5"}
+	if err := state.record(res, "hash"); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	// A later failure must not lose the PR reference
+	failed := result{Key: "k", Repo: "org/r", Base: "main", Head: "dev", Status: statusFailed, Error: "boom"}
+	if err := state.record(failed, "hash"); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState failed: %v", err)
+	}
+	entry := loaded.Entries["k"]
+	if entry.Status != statusFailed || entry.URL != "https://github.com/org/r/pull/5" || entry.Number != 5 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+func TestLoadStateRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(path, []byte(`{"version": 99, "entries": {}}`), 0o644)
+	if _, err := loadState(path); err == nil || !strings.Contains(err.Error(), "unsupported state file version") {
+		t.Errorf("Expected version error, got %v", err)
+	}
+}
+
+func TestCreatePullRequestResume(t *testing.T) {
+	originalMockRunCommand := mockRunCommand
+	defer func() { mockRunCommand = originalMockRunCommand }()
+
+	var mu sync.Mutex
+	created := map[string]int{}
+	failRepo := "org/b"
+	mockRunCommand = func(args ...string) error {
+		if args[2] != "create" {
+			return nil
+		}
+		repo := args[len(args)-1]
+		if repo == failRepo {
+			return newCommandError(args, "HTTP 422: Validation Failed", os.ErrInvalid)
+		}
+		mu.Lock()
+		created[repo]++
+		mu.Unlock()
+		return nil
+	}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	newConfig := func(resume bool) *Config {
+		return &Config{Preflight: new(bool), State: statePath, Resume: resume, Repos: map[string]Repo{
+			"a": {Repo: "org/a", Base: "main", Head: "dev", Title: "T", Body: "B"},
+			"b": {Repo: "org/b", Base: "main", Head: "dev", Title: "T", Body: "B"},
+		}}
+	}
+
+	if err := createPullRequest(newConfig(false), false); err == nil {
+		t.Fatal("Expected first run to fail for org/b")
+	}
+
+	failRepo = ""
+	if err := createPullRequest(newConfig(true), false); err != nil {
+		t.Fatalf("Expected resumed run to succeed, got %v", err)
+	}
+	if created["org/a"] != 1 || created["org/b"] != 1 {
+		t.Errorf("Expected each repo to be created exactly once, got %v", created)
+	}
+
+	// A changed configuration is attempted again even when resuming
+	config := newConfig(true)
+	config.Repos["a"] = Repo{Repo: "org/a", Base: "main", Head: "dev", Title: "New title", Body: "B"}
+	if err := createPullRequest(config, false); err != nil {
+		t.Fatalf("Expected run to succeed, got %v", err)
+	}
+	if created["org/a"] != 2 || created["org/b"] != 1 {
+		t.Errorf("Expected only the changed entry to be attempted, got %v", created)
+	}
+
+	state, _ := loadState(statePath)
+	if state.Entries["b"].Status != statusSkipped || state.Entries["a"].Status != statusCreated {
+		t.Errorf("Unexpected state entries: %+v", state.Entries)
+	}
+}
+
+func TestCreatePullRequestResumeRequiresState(t *testing.T) {
+	config := &Config{Resume: true, Repos: map[string]Repo{"a": {Repo: "org/a", Base: "main", Head: "dev"}}}
+	if err := createPullRequest(config, true); err == nil || !strings.Contains(err.Error(), "requires a state file") {
+		t.Errorf("Expected missing state file error, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// States of a pull request as reported by the status command
+const (
+	prStateOpen   = "open"
+	prStateDraft  = "draft"
+	prStateMerged = "merged"
+	prStateClosed = "closed"
+)
+
+// Rollups of the check runs and commit statuses of a pull request
+const (
+	checksNone    = "none"
+	checksPending = "pending"
+	checksSuccess = "success"
+	checksFailure = "failure"
+)
+
+// Review decisions, following GitHub's reviewDecision
+const (
+	reviewApproved         = "approved"
+	reviewChangesRequested = "changes_requested"
+	reviewRequired         = "review_required"
+)
+
+// pullRequestStatus is the current state of a pull request
+type pullRequestStatus struct {
+	pullRequest
+	State          string // One of the prState constants
+	ReviewDecision string // One of the review constants, or empty when no review is required
+	Checks         string // One of the checks constants
+	Mergeable      string // "mergeable", "conflicting" or "unknown"
+}
+
+// statusReader is implemented by backends that can report the state of pull requests
+type statusReader interface {
+	// pullRequestStatus returns the most recent pull request for the repo, base and head of details
+	// in any state, or nil when there is none
+	pullRequestStatus(ctx context.Context, details Repo) (*pullRequestStatus, error)
+}
+
+// checkState is a check run or a commit status, as reported by GitHub
+type checkState struct {
+	Status     string `json:"status"`     // Check runs: queued, in_progress or completed
+	Conclusion string `json:"conclusion"` // Check runs, once completed: success, failure, neutral, skipped, ...
+	State      string `json:"state"`      // Commit statuses: pending, success, failure or error
+}
+
+// rollupChecks combines check runs and commit statuses into a single result:
+// any failure fails the rollup, otherwise anything unfinished keeps it pending
+func rollupChecks(checks []checkState) string {
+	if len(checks) == 0 {
+		return checksNone
+	}
+
+	rollup := checksSuccess
+	for _, check := range checks {
+		switch {
+		case check.State != "":
+			switch strings.ToLower(check.State) {
+			case "failure", "error":
+				return checksFailure
+			case "pending", "expected":
+				rollup = checksPending
+			}
+		case !strings.EqualFold(check.Status, "completed"):
+			rollup = checksPending
+		default:
+			switch strings.ToLower(check.Conclusion) {
+			case "failure", "timed_out", "cancelled", "action_required", "startup_failure":
+				return checksFailure
+			}
+		}
+	}
+	return rollup
+}
+
+// lookupStatus returns the state of the pull request of details, or nil when there is none
+func lookupStatus(ctx context.Context, config *Config, details Repo) (*pullRequestStatus, error) {
+	reader, err := backendWith[statusReader](config, details, "status")
+	if err != nil {
+		return nil, err
+	}
+	return reader.pullRequestStatus(ctx, details)
+}
+
+// lookupPullRequest returns the most recent pull request of details with its state, or nil when there is none.
+// Backends that cannot report states only find open pull requests.
+func lookupPullRequest(ctx context.Context, config *Config, details Repo) (*pullRequestStatus, error) {
+	be, err := backendFor(config, details)
+	if err != nil {
+		return nil, err
+	}
+	if reader, ok := be.(statusReader); ok {
+		return reader.pullRequestStatus(ctx, details)
+	}
+
+	pr, err := be.findPullRequest(ctx, details)
+	if err != nil || pr == nil {
+		return nil, err
+	}
+	return &pullRequestStatus{pullRequest: *pr, State: prStateOpen}, nil
+}
+
+func runStatus(args []string) error {
+	fs := newCommandFlagSet("status", "Show the state, review decision, checks and mergeability of the PR of every entry.")
+	flags := addCampaignFlags(fs)
+	config, err := loadCampaign(fs, flags, args)
+	if err != nil {
+		return err
+	}
+	return campaignStatus(config)
+}
+
+// campaignStatus looks up the pull request of every entry and reports its state.
+// In text mode the result is a table; entries without a pull request have the status "not_found".
+func campaignStatus(config *Config) error {
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return err
+	}
+
+	retry := newRetrier(config)
+	rep := newReporter(os.Stdout, config.Output)
+	forEachRepo(config.Concurrency, repoNames, prepared, func(repoName string, details Repo) {
+		res := newResult(repoName, details)
+		var status *pullRequestStatus
+		err := retry.do(context.Background(), "Looking up PR status for "+repoName, func(ctx context.Context) (err error) {
+			status, err = lookupStatus(ctx, config, details)
+			return err
+		})
+		switch {
+		case err != nil:
+			log.Printf("Failed to look up PR status for %s: %v\n", repoName, err)
+			res = res.finish(statusFailed, fmt.Errorf("failed to look up PR status for %s: %w", repoName, err))
+		case status == nil:
+			res = res.finish(statusNotFound, nil)
+		default:
+			res.Number, res.URL = status.Number, status.URL
+			res.ReviewDecision, res.Checks, res.Mergeable = status.ReviewDecision, status.Checks, status.Mergeable
+			res = res.finish(status.State, nil)
+		}
+		rep.add(res)
+	})
+
+	if err := rep.finish(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if !machineReadable(config.Output) {
+		printStatusTable(rep.results)
+	}
+
+	for _, res := range rep.results {
+		if res.Status == statusFailed {
+			return fmt.Errorf("failed to look up the status of one or more pull requests (first error: %w)", res.err)
+		}
+	}
+	return nil
+}
+
+// printStatusTable writes the results of the status command as an aligned table
+func printStatusTable(results []result) {
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tREPO\tPR\tSTATE\tREVIEW\tCHECKS\tMERGEABLE")
+	for _, res := range results {
+		number := "-"
+		if res.Number != 0 {
+			number = "#" + strconv.Itoa(res.Number)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.Key, res.Repo, number, res.Status,
+			orDash(res.ReviewDecision), orDash(res.Checks), orDash(res.Mergeable))
+	}
+	w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRollupChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []checkState
+		want   string
+	}{
+		{"none", nil, checksNone},
+		{"all passed", []checkState{{Status: "completed", Conclusion: "success"}, {Status: "COMPLETED", Conclusion: "SKIPPED"}, {State: "success"}}, checksSuccess},
+		{"running", []checkState{{Status: "completed", Conclusion: "success"}, {Status: "in_progress"}}, checksPending},
+		{"pending status", []checkState{{State: "PENDING"}}, checksPending},
+		{"failed run wins over pending", []checkState{{Status: "queued"}, {Status: "completed", Conclusion: "failure"}}, checksFailure},
+		{"errored status", []checkState{{State: "error"}}, checksFailure},
+	}
+	for _, tt := range tests {
+		if got := rollupChecks(tt.checks); got != tt.want {
+			t.Errorf("%s: rollupChecks = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCampaignStatus(t *testing.T) {
+	be := useMemoryBackend(t)
+	open := be.add(Repo{Repo: "org/a", Base: "main", Head: "dev"})
+	open.Review, open.Checks, open.Mergeable = reviewApproved, checksSuccess, "mergeable"
+	be.add(Repo{Repo: "org/b", Base: "main", Head: "dev"}).Merged = true
+
+	newConfig := func(output string) *Config {
+		return &Config{Output: output, Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+			"a": {Repo: "org/a", Base: "main", Head: "dev"},
+			"b": {Repo: "org/b", Base: "main", Head: "dev"},
+			"c": {Repo: "org/c", Base: "main", Head: "dev"},
+		}}
+	}
+
+	output := captureStdout(t, func() {
+		if err := campaignStatus(newConfig("")); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "KEY") {
+		t.Fatalf("Expected a header and 3 rows, got:\n%s", output)
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "a org/a #1 open approved success mergeable" {
+		t.Errorf("Unexpected row for a: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[3] != prStateMerged {
+		t.Errorf("Unexpected row for b: %q", lines[2])
+	}
+	if fields := strings.Fields(lines[3]); fields[2] != "-" || fields[3] != statusNotFound {
+		t.Errorf("Unexpected row for c: %q", lines[3])
+	}
+
+	output = captureStdout(t, func() {
+		if err := campaignStatus(newConfig(outputJSON)); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	var report struct {
+		Results []result
+		Summary reportSummary
+	}
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, output)
+	}
+	if report.Summary.Status[prStateOpen] != 1 || report.Summary.Status[prStateMerged] != 1 || report.Summary.Status[statusNotFound] != 1 {
+		t.Errorf("Unexpected summary: %+v", report.Summary)
+	}
+}
+
+func TestCampaignStatusUnsupportedProvider(t *testing.T) {
+	// A backend without status support
+	mockBackend = struct{ backend }{useMemoryBackend(t)}
+
+	config := &Config{Output: outputJSON, Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+		"svc": {Repo: "PRJ/svc", Base: "main", Head: "dev", Provider: providerBitbucket, Host: "bitbucket.example.com"},
+	}}
+	var err error
+	captureStdout(t, func() { err = campaignStatus(config) })
+	if err == nil || !strings.Contains(err.Error(), `status is not supported by provider "bitbucket"`) {
+		t.Errorf("Expected unsupported operation error, got %v", err)
+	}
+}
+
+func TestGHBackendPullRequestStatus(t *testing.T) {
+	originalMockRunCommandOutput := mockRunCommandOutput
+	defer func() { mockRunCommandOutput = originalMockRunCommandOutput }()
+
+	var gotArgs []string
+	mockRunCommandOutput = func(args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte(`[{"number": 4, "url": "https://github.com/org/r/pull/4", "state": "OPEN", "isDraft": true,
+			"reviewDecision": "REVIEW_REQUIRED", "mergeable": "CONFLICTING",
+			"statusCheckRollup": [{"__typename": "CheckRun", "status": "COMPLETED", "conclusion": "SUCCESS"}, {"__typename": "StatusContext", "state": "PENDING"}]}]`), nil
+	}
+
+	status, err := ghBackend{}.pullRequestStatus(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	if err != nil {
+		t.Fatalf("pullRequestStatus failed: %v", err)
+	}
+	want := pullRequestStatus{pullRequest{4, "https://github.com/org/r/pull/4"}, prStateDraft, reviewRequired, checksPending, "conflicting"}
+	if *status != want {
+		t.Errorf("pullRequestStatus = %+v, want %+v", *status, want)
+	}
+	if !strings.Contains(strings.Join(gotArgs, " "), "--state all") {
+		t.Errorf("Expected PRs in any state to be listed, got %v", gotArgs)
+	}
+}
+
+func TestGitHubBackendPullRequestStatus(t *testing.T) {
+	decision := "APPROVED"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/r/pulls":
+			if r.URL.Query().Get("state") != "all" || r.URL.Query().Get("head") != "org:dev" {
+				t.Errorf("Unexpected PR list query: %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"number": 9}]`)
+		case "/repos/org/r/pulls/9":
+			fmt.Fprint(w, `{"number": 9, "node_id": "PR_9", "html_url": "https://github.com/org/r/pull/9", "state": "open", "mergeable": true, "head": {"sha": "abc"}}`)
+		case "/graphql":
+			var body struct{ Variables map[string]string }
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Variables["id"] != "PR_9" {
+				t.Errorf("Unexpected GraphQL variables: %v", body.Variables)
+			}
+			fmt.Fprintf(w, `{"data": {"node": {"reviewDecision": %q}}}`, decision)
+		case "/repos/org/r/commits/abc/check-runs":
+			fmt.Fprint(w, `{"check_runs": [{"status": "completed", "conclusion": "failure"}]}`)
+		case "/repos/org/r/commits/abc/status":
+			fmt.Fprint(w, `{"statuses": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	be := newGitHubBackend(server.URL, "secret")
+	status, err := be.pullRequestStatus(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	if err != nil {
+		t.Fatalf("pullRequestStatus failed: %v", err)
+	}
+	want := pullRequestStatus{pullRequest{9, "https://github.com/org/r/pull/9"}, prStateOpen, reviewApproved, checksFailure, "mergeable"}
+	if *status != want {
+		t.Errorf("pullRequestStatus = %+v, want %+v", *status, want)
+	}
+
+	// A PR without reviews where branch protection requires one must not look mergeable
+	decision = "REVIEW_REQUIRED"
+	status, err = be.pullRequestStatus(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	if err != nil || status.ReviewDecision != reviewRequired {
+		t.Errorf("Expected %s, got %+v, %v", reviewRequired, status, err)
+	}
+}
+
+func TestGitLabBackendPullRequestStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/org%2Fr/merge_requests":
+			if r.URL.Query().Get("state") != "all" || r.URL.Query().Get("source_branch") != "dev" {
+				t.Errorf("Unexpected MR list query: %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `[{"iid": 5}]`)
+		case "/api/v4/projects/org%2Fr/merge_requests/5":
+			fmt.Fprint(w, `{"iid": 5, "web_url": "https://gitlab.test/org/r/-/merge_requests/5", "state": "opened", "draft": true,
+				"has_conflicts": false, "detailed_merge_status": "draft_status", "head_pipeline": {"status": "running"}}`)
+		case "/api/v4/projects/org%2Fr/merge_requests/5/approvals":
+			fmt.Fprint(w, `{"approvals_left": 1, "approved_by": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	status, err := newGitLabBackend(server.URL+"/api/v4", "secret").pullRequestStatus(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	if err != nil {
+		t.Fatalf("pullRequestStatus failed: %v", err)
+	}
+	want := pullRequestStatus{pullRequest{5, "https://gitlab.test/org/r/-/merge_requests/5"}, prStateDraft, reviewRequired, checksPending, "mergeable"}
+	if *status != want {
+		t.Errorf("pullRequestStatus = %+v, want %+v", *status, want)
+	}
+}
+
+func TestGiteaBackendPullRequestStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/org/r/pulls":
+			fmt.Fprint(w, `[{"number": 2, "state": "closed", "base": {"ref": "main"}, "head": {"ref": "dev"}},
+				{"number": 4, "html_url": "https://gitea.test/org/r/pulls/4", "state": "open", "mergeable": true, "base": {"ref": "main"}, "head": {"ref": "dev", "sha": "abc"}},
+				{"number": 6, "state": "open", "base": {"ref": "release"}, "head": {"ref": "dev"}}]`)
+		case "/api/v1/repos/org/r/pulls/4/reviews":
+			fmt.Fprint(w, `[{"state": "REQUEST_CHANGES", "user": {"login": "bob"}}, {"state": "APPROVED", "user": {"login": "bob"}},
+				{"state": "APPROVED", "stale": true, "user": {"login": "carol"}}, {"state": "COMMENT", "user": {"login": "dave"}}]`)
+		case "/api/v1/repos/org/r/commits/abc/status":
+			fmt.Fprint(w, `{"statuses": [{"status": "success"}, {"status": "failure"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	status, err := newGiteaBackend(server.URL+"/api/v1", "secret").pullRequestStatus(context.Background(), Repo{Repo: "org/r", Base: "main", Head: "dev"})
+	if err != nil {
+		t.Fatalf("pullRequestStatus failed: %v", err)
+	}
+	want := pullRequestStatus{pullRequest{4, "https://gitea.test/org/r/pulls/4"}, prStateOpen, reviewApproved, checksFailure, "mergeable"}
+	if *status != want {
+		t.Errorf("pullRequestStatus = %+v, want %+v", *status, want)
+	}
+}
+
+func TestBitbucketBackendPullRequestStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/1.0/projects/PRJ/repos/svc/pull-requests":
+			if r.URL.Query().Get("state") != "ALL" {
+				t.Errorf("Unexpected PR list query: %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"values": [{"id": 8, "state": "OPEN", "toRef": {"id": "refs/heads/release"}},
+				{"id": 3, "state": "OPEN", "fromRef": {"latestCommit": "abc"}, "toRef": {"id": "refs/heads/main"},
+				"reviewers": [{"status": "APPROVED"}, {"status": "NEEDS_WORK"}],
+				"links": {"self": [{"href": "https://bitbucket.test/projects/PRJ/repos/svc/pull-requests/3"}]}}]}`)
+		case "/rest/api/1.0/projects/PRJ/repos/svc/pull-requests/3/merge":
+			fmt.Fprint(w, `{"canMerge": false, "conflicted": true}`)
+		case "/rest/build-status/1.0/commits/abc":
+			fmt.Fprint(w, `{"values": [{"state": "SUCCESSFUL"}, {"state": "INPROGRESS"}], "isLastPage": true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	status, err := newBitbucketBackend(server.URL+"/rest/api/1.0", "secret").pullRequestStatus(context.Background(), Repo{Repo: "PRJ/svc", Base: "main", Head: "dev"})
+	if err != nil {
+		t.Fatalf("pullRequestStatus failed: %v", err)
+	}
+	want := pullRequestStatus{pullRequest{3, "https://bitbucket.test/projects/PRJ/repos/svc/pull-requests/3"}, prStateOpen, reviewChangesRequested, checksPending, "conflicting"}
+	if *status != want {
+		t.Errorf("pullRequestStatus = %+v, want %+v", *status, want)
+	}
+}
+
+func TestRunCommandLineStatus(t *testing.T) {
+	useMemoryBackend(t)
+	configFile := createTempYAMLFile(t, `repos: {a: {repo: "org/a", base: "main", head: "dev", title: "T"}}`)
+	defer os.Remove(configFile)
+
+	var handled bool
+	output := captureStdout(t, func() { handled = runCommandLine([]string{"status", "--output", "ndjson", configFile}) })
+	if !handled {
+		t.Fatal("Expected status to be handled as a subcommand")
+	}
+	if !strings.Contains(output, `"status":"not_found"`) {
+		t.Errorf("Expected an NDJSON status line, got %q", output)
+	}
+	if runCommandLine([]string{configFile}) {
+		t.Error("Expected a config file not to be taken for a subcommand")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateData is the value passed to the title, body and head templates
+type templateData struct {
+	Key   string            // Key of the entry under `repos:`
+	Owner string            // Owner part of `repo`
+	Name  string            // Name part of `repo`
+	Repo  string            // Full `owner/name`
+	Base  string            // Base branch
+	Head  string            // Head branch (already rendered when used in title and body)
+	Vars  map[string]string // User-defined variables from `vars:`
+}
+
+// templateFuncs are the helper functions available inside templates.
+// Arguments are ordered so the piped value comes last, e.g. {{ .Name | replace "-" "_" }}
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"trimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+	"trimSuffix": func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	},
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// newTemplateData builds the template data for a repository entry
+func newTemplateData(key string, details Repo) templateData {
+	owner, name, _ := strings.Cut(details.Repo, "/")
+	vars := details.Vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	return templateData{
+		Key:   key,
+		Owner: owner,
+		Name:  name,
+		Repo:  details.Repo,
+		Base:  details.Base,
+		Head:  details.Head,
+		Vars:  vars,
+	}
+}
+
+// renderTemplate executes a single template string against data
+func renderTemplate(name, text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// renderRepo renders the head, title, body, commit message, script and edit templates of a repository entry.
+// The head is rendered first so that title and body can refer to the final branch name.
+func renderRepo(key string, details Repo) (Repo, error) {
+	data := newTemplateData(key, details)
+
+	head, err := renderTemplate("head", details.Head, data)
+	if err != nil {
+		return details, fmt.Errorf("repo %s: invalid head template: %w", key, err)
+	}
+	details.Head = head
+	data.Head = head
+
+	title, err := renderTemplate("title", details.Title, data)
+	if err != nil {
+		return details, fmt.Errorf("repo %s: invalid title template: %w", key, err)
+	}
+	details.Title = title
+
+	body, err := renderTemplate("body", details.Body, data)
+	if err != nil {
+		return details, fmt.Errorf("repo %s: invalid body template: %w", key, err)
+	}
+	details.Body = body
+
+	message, err := renderTemplate("commit_message", details.CommitMessage, data)
+	if err != nil {
+		return details, fmt.Errorf("repo %s: invalid commit_message template: %w", key, err)
+	}
+	details.CommitMessage = message
+
+	script, err := renderTemplate("script", details.Script, data)
+	if err != nil {
+		return details, fmt.Errorf("repo %s: invalid script template: %w", key, err)
+	}
+	details.Script = script
+
+	edits, err := renderEdits(details.Edits, data)
+	if err != nil {
+		return details, fmt.Errorf("repo %s: invalid edits template: %w", key, err)
+	}
+	details.Edits = edits
+
+	return details, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderRepo(t *testing.T) {
+	details := Repo{
+		Repo:  "org/payments-service",
+		Base:  "main",
+		Head:  "bump/{{ .Vars.version }}",
+		Title: "[{{ .Name | upper }}] Bump to {{ .Vars.version }}",
+		Body:  "Owner: {{ .Owner }}, key: {{ .Key }}, {{ .Base }} <- {{ .Head }}",
+		Vars:  map[string]string{"version": "v1.2.3"},
+	}
+
+	rendered, err := renderRepo("payments", details)
+	if err != nil {
+		t.Fatalf("renderRepo failed: %v", err)
+	}
+	if rendered.Head != "bump/v1.2.3" {
+		t.Errorf("Head mismatch: got %q", rendered.Head)
+	}
+	if rendered.Title != "[PAYMENTS-SERVICE] Bump to v1.2.3" {
+		t.Errorf("Title mismatch: got %q", rendered.Title)
+	}
+	if rendered.Body != "Owner: org, key: payments, main <- bump/v1.2.3" {
+		t.Errorf("Body mismatch: got %q", rendered.Body)
+	}
+}
+
+func TestRenderRepoFuncs(t *testing.T) {
+	details := Repo{Repo: "org/my-svc", Base: "main", Head: "h", Title: `{{ .Name | replace "-" "_" }} {{ .Vars.missing | default "none" }}`, Vars: map[string]string{"missing": ""}}
+	rendered, err := renderRepo("k", details)
+	if err != nil {
+		t.Fatalf("renderRepo failed: %v", err)
+	}
+	if rendered.Title != "my_svc none" {
+		t.Errorf("Title mismatch: got %q", rendered.Title)
+	}
+}
+
+func TestRenderRepoErrors(t *testing.T) {
+	t.Run("ParseError", func(t *testing.T) {
+		_, err := renderRepo("k", Repo{Repo: "o/r", Base: "b", Head: "h", Title: "{{ .Name "})
+		if err == nil || !strings.Contains(err.Error(), "invalid title template") {
+			t.Errorf("Expected title template error, got %v", err)
+		}
+	})
+
+	t.Run("MissingVar", func(t *testing.T) {
+		_, err := renderRepo("k", Repo{Repo: "o/r", Base: "b", Head: "h", Title: "T", Body: "{{ .Vars.nope }}"})
+		if err == nil || !strings.Contains(err.Error(), "invalid body template") {
+			t.Errorf("Expected body template error, got %v", err)
+		}
+	})
+}
+
+func TestCreatePullRequestTemplateErrorCreatesNothing(t *testing.T) {
+	originalMockRunCommand := mockRunCommand
+	defer func() { mockRunCommand = originalMockRunCommand }()
+
+	calls := 0
+	mockRunCommand = func(args ...string) error { calls++; return nil }
+
+	config := &Config{Repos: map[string]Repo{
+		"good": {Repo: "org/good", Base: "main", Head: "dev", Title: "{{ .Name }}", Body: "B"},
+		"bad":  {Repo: "org/bad", Base: "main", Head: "dev", Title: "{{ .Vars.undefined }}", Body: "B"},
+	}}
+
+	err := createPullRequest(config, false)
+	if err == nil {
+		t.Fatal("Expected template error, got nil")
+	}
+	if !strings.Contains(err.Error(), "repo bad") {
+		t.Errorf("Expected error to name the failing repo, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no commands to run, got %d", calls)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultConcurrency is the number of workers used when neither --concurrency nor `concurrency` is set
+const defaultConcurrency = 4
+
+// throttle enforces a minimum interval between operations shared by all workers
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Allow tests to avoid real sleeps
+var sleep = time.Sleep
+
+// newThrottle returns a throttle, or nil when no interval is configured
+func newThrottle(interval time.Duration) *throttle {
+	if interval <= 0 {
+		return nil
+	}
+	return &throttle{interval: interval}
+}
+
+// wait blocks until at least interval has passed since the previous call returned
+func (t *throttle) wait() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	start := now
+	if t.next.After(now) {
+		start = t.next
+	}
+	t.next = start.Add(t.interval)
+	t.mu.Unlock()
+
+	if d := start.Sub(now); d > 0 {
+		sleep(d)
+	}
+}
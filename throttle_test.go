@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleWait(t *testing.T) {
+	originalSleep := sleep
+	defer func() { sleep = originalSleep }()
+
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	th := newThrottle(time.Hour)
+	th.wait()
+	th.wait()
+	th.wait()
+
+	if len(slept) != 2 {
+		t.Fatalf("Expected 2 sleeps, got %v", slept)
+	}
+	if slept[0] <= 59*time.Minute || slept[1] <= 119*time.Minute {
+		t.Errorf("Expected sleeps of about 1h and 2h, got %v", slept)
+	}
+}
+
+func TestNilThrottleDoesNotWait(t *testing.T) {
+	var th *throttle = newThrottle(0)
+	if th != nil {
+		t.Fatalf("Expected nil throttle for zero interval")
+	}
+	th.wait()
+}
+
+func TestCreatePullRequestConcurrencyLimit(t *testing.T) {
+	originalMockRunCommand := mockRunCommand
+	defer func() { mockRunCommand = originalMockRunCommand }()
+
+	var mu sync.Mutex
+	inFlight, maxInFlight, created := 0, 0, 0
+	mockRunCommand = func(args ...string) error {
+		if args[2] != "create" {
+			return nil
+		}
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		created++
+		mu.Unlock()
+		return nil
+	}
+
+	repos := make(map[string]Repo)
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("repo-%d", i)
+		repos[name] = Repo{Repo: "org/" + name, Base: "main", Head: "dev", Title: "T", Body: "B"}
+	}
+
+	if err := createPullRequest(&Config{Preflight: new(bool), Repos: repos, Concurrency: 3}, false); err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	if created != 12 {
+		t.Errorf("Expected 12 PRs, got %d", created)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 concurrent creations, got %d", maxInFlight)
+	}
+}
+
+func TestReadYAMLConfigConcurrencySettings(t *testing.T) {
+	file := createTempYAMLFile(t, `
+concurrency: 8
+delay: 1500ms
+repos:
+  r: {repo: "org/r", base: "main", head: "dev", title: "T", body: "B"}
+`)
+	defer os.Remove(file)
+
+	config, err := readYAMLConfig([]string{file})
+	if err != nil {
+		t.Fatalf("Failed to read YAML config: %v", err)
+	}
+	if config.Concurrency != 8 {
+		t.Errorf("Expected concurrency 8, got %d", config.Concurrency)
+	}
+	if config.Delay != 1500*time.Millisecond {
+		t.Errorf("Expected delay 1.5s, got %v", config.Delay)
+	}
+}
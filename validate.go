@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func runValidate(args []string) error {
+	fs := newCommandFlagSet("validate", "Check a campaign without creating anything. Problems in the files are reported with their line.\nWith --remote, every entry is also checked against the forge: repository, branches, permissions, labels, assignees and reviewers.")
+	flags := addCampaignFlags(fs)
+	remote := fs.Bool("remote", false, "Also run the preflight checks against the forge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		return fmt.Errorf("usage: bulkpr validate [flags] <config-file1> [config-file2] ...")
+	}
+
+	issues, err := lintConfigFiles(files)
+	if err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			log.Printf("  %s\n", issue)
+		}
+		return fmt.Errorf("found %d %s in the configuration", len(issues), plural(len(issues), "problem", "problems"))
+	}
+
+	config, err := loadCampaign(fs, flags, files)
+	if err != nil {
+		return err
+	}
+	return validateCampaign(config, *remote)
+}
+
+// validateCampaign checks the entries of a campaign and, with remote, runs the preflight checks.
+// It returns an error if any entry has a problem.
+func validateCampaign(config *Config, remote bool) error {
+	if err := validateExistingPolicy(config.OnExisting); err != nil {
+		return err
+	}
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return err
+	}
+	if err := validatePrepared(config, repoNames, prepared); err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, repoName := range repoNames {
+		if _, ok := prepared[repoName]; !ok {
+			log.Printf("  repo %s: missing %s\n", repoName, strings.Join(missingFields(config.Repos[repoName]), ", "))
+			problems++
+		}
+	}
+	if remote {
+		failed, unchecked := runPreflight(config, repoNames, prepared)
+		for _, repoName := range unchecked {
+			log.Printf("  repo %s: preflight checks are not supported by provider %q\n", repoName, providerName(prepared[repoName]))
+		}
+		problems += len(failed) + len(unchecked)
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d of %d entries have problems", problems, len(repoNames))
+	}
+	fmt.Fprintf(progressOutput(config.Output), "All %d entries are valid\n", len(repoNames))
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of the watch command and --wait
+const (
+	defaultWaitTimeout  = 30 * time.Minute
+	defaultPollInterval = 30 * time.Second
+	defaultChecksGrace  = 5 * time.Minute
+)
+
+// watchOptions are the settings of the watch command and --wait
+type watchOptions struct {
+	timeout  time.Duration // How long to wait for all checks to complete
+	interval time.Duration // Time between two polls
+	grace    time.Duration // How long a PR without any checks is polled until checks appear
+}
+
+// watchFlags are the flags controlling how long checks are waited for
+type watchFlags struct {
+	timeout  *time.Duration
+	interval *time.Duration
+	grace    *time.Duration
+}
+
+// addWatchFlags registers the watch flags on fs
+func addWatchFlags(fs *flag.FlagSet) *watchFlags {
+	return &watchFlags{
+		timeout:  fs.Duration("wait-timeout", defaultWaitTimeout, "How long to wait for the checks of all PRs to complete"),
+		interval: fs.Duration("poll-interval", defaultPollInterval, "Time between two polls of the checks"),
+		grace:    fs.Duration("checks-grace", defaultChecksGrace, "How long to wait for the checks of a PR without any to appear"),
+	}
+}
+
+func (f *watchFlags) options() watchOptions {
+	return watchOptions{timeout: *f.timeout, interval: *f.interval, grace: *f.grace}
+}
+
+func runWatch(args []string) error {
+	fs := newCommandFlagSet("watch", "Wait until the checks of every PR completed, then report which passed and which failed.")
+	flags := addCampaignFlags(fs)
+	wait := addWatchFlags(fs)
+	config, err := loadCampaign(fs, flags, args)
+	if err != nil {
+		return err
+	}
+	return watchChecks(config, wait.options())
+}
+
+// watchChecks polls the checks of the pull request of every entry until none is pending
+// or the timeout is reached, and reports the outcome per entry.
+// Entries whose checks failed or did not complete in time make it return an error.
+func watchChecks(config *Config, opts watchOptions) error {
+	repoNames, prepared, err := prepareRepos(config)
+	if err != nil {
+		return err
+	}
+	return watchPrepared(config, repoNames, prepared, opts)
+}
+
+// watchPrepared is watchChecks for entries that were already prepared, e.g. by a campaign run.
+// A PR without any checks is polled until checks appear or the grace period expired,
+// since CI usually registers its checks some time after the PR was opened.
+func watchPrepared(config *Config, repoNames []string, prepared map[string]Repo, opts watchOptions) error {
+	if opts.interval <= 0 {
+		opts.interval = defaultPollInterval
+	}
+
+	retry := newRetrier(config)
+	stdout := progressOutput(config.Output)
+	rep := newReporter(os.Stdout, config.Output)
+	deadline := time.Now().Add(opts.timeout)
+
+	var mu sync.Mutex
+	started := time.Now()
+	var waited time.Duration
+	pending := repoNames
+	latest := map[string]result{}
+	for round := 1; ; round++ {
+		var stillPending []string
+		forEachRepo(config.Concurrency, pending, prepared, func(repoName string, details Repo) {
+			res := newResult(repoName, details)
+			res.StartedAt = started
+
+			var status *pullRequestStatus
+			err := retry.do(context.Background(), "Looking up checks for "+repoName, func(ctx context.Context) (err error) {
+				status, err = lookupStatus(ctx, config, details)
+				return err
+			})
+			switch {
+			case err != nil:
+				log.Printf("Failed to look up checks for %s: %v\n", repoName, err)
+				res = res.finish(statusFailed, fmt.Errorf("failed to look up checks for %s: %w", repoName, err))
+			case status == nil:
+				res = res.finish(statusNotFound, fmt.Errorf("no PR found for %s", repoName))
+			default:
+				res.Number, res.URL = status.Number, status.URL
+				res.ReviewDecision, res.Checks, res.Mergeable = status.ReviewDecision, status.Checks, status.Mergeable
+				switch status.Checks {
+				case checksPending:
+					res = res.finish(checksPending, nil)
+				case checksNone:
+					if waited < opts.grace {
+						res = res.finish(checksPending, nil)
+					} else {
+						fmt.Fprintf(stdout, "No checks for %s (%s)\n", repoName, status.URL)
+						res = res.finish(checksNone, nil)
+					}
+				case checksFailure:
+					fmt.Fprintf(stdout, "Checks failed for %s (%s)\n", repoName, status.URL)
+					res = res.finish(checksFailure, fmt.Errorf("checks failed for %s: %s", repoName, status.URL))
+				default:
+					fmt.Fprintf(stdout, "Checks passed for %s (%s)\n", repoName, status.URL)
+					res = res.finish(status.Checks, nil)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			latest[repoName] = res
+			if res.Status == checksPending {
+				stillPending = append(stillPending, repoName)
+			} else {
+				rep.add(res)
+			}
+		})
+
+		if len(stillPending) == 0 {
+			break
+		}
+		sort.Strings(stillPending)
+		pending = stillPending
+		if time.Now().Add(opts.interval).After(deadline) {
+			for _, repoName := range pending {
+				res := latest[repoName]
+				if res.Checks == checksNone {
+					fmt.Fprintf(stdout, "No checks for %s (%s)\n", repoName, res.URL)
+					rep.add(res.finish(checksNone, nil))
+					continue
+				}
+				rep.add(res.finish(checksPending, fmt.Errorf("checks for %s did not complete within %s", repoName, opts.timeout)))
+			}
+			break
+		}
+		fmt.Fprintf(stdout, "Waiting for the checks of %d PRs (poll %d)...\n", len(pending), round)
+		sleep(opts.interval)
+		waited += opts.interval
+	}
+
+	if err := rep.finish(); err != nil {
+		log.Printf("Failed to write report: %v\n", err)
+	}
+
+	counts := map[string]int{}
+	var failures []result
+	for _, res := range rep.results {
+		counts[res.Status]++
+		if res.err != nil {
+			failures = append(failures, res)
+		}
+	}
+	fmt.Fprintf(stdout, "Checks passed: %d, failed: %d, timed out: %d, no checks: %d, not found: %d, errors: %d\n",
+		counts[checksSuccess], counts[checksFailure], counts[checksPending], counts[checksNone], counts[statusNotFound], counts[statusFailed])
+
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Key < failures[j].Key })
+		for _, res := range failures {
+			log.Printf("  %s [%s] %s\n", res.Key, res.Status, res.Error)
+		}
+		return fmt.Errorf("the checks of one or more pull requests did not pass (first error: %w)", failures[0].err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchChecks(t *testing.T) {
+	slept := mockSleep(t)
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/fast", Base: "main", Head: "dev"}).Checks = checksSuccess
+	be.add(Repo{Repo: "org/slow", Base: "main", Head: "dev"}).Checks = checksPending
+	be.add(Repo{Repo: "org/red", Base: "main", Head: "dev"}).Checks = checksPending
+
+	polls := map[string]int{}
+	be.onStatus = func(pr *memoryPR) {
+		polls[pr.Repo]++
+		if polls[pr.Repo] == 3 {
+			pr.Checks = map[string]string{"org/slow": checksSuccess, "org/red": checksFailure}[pr.Repo]
+		}
+	}
+
+	config := &Config{Output: outputJSON, Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+		"fast": {Repo: "org/fast", Base: "main", Head: "dev"},
+		"slow": {Repo: "org/slow", Base: "main", Head: "dev"},
+		"red":  {Repo: "org/red", Base: "main", Head: "dev"},
+	}}
+	var err error
+	output := captureStdout(t, func() {
+		err = watchChecks(config, watchOptions{timeout: time.Hour, interval: time.Minute})
+	})
+	if err == nil || !strings.Contains(err.Error(), "checks failed for red") {
+		t.Errorf("Expected the failed checks to be reported, got %v", err)
+	}
+	if len(*slept) != 2 || (*slept)[0] != time.Minute {
+		t.Errorf("Expected two waits of the poll interval, got %v", *slept)
+	}
+	if polls["org/fast"] != 1 {
+		t.Errorf("Expected completed checks not to be polled again, got %d polls", polls["org/fast"])
+	}
+
+	var report struct{ Summary reportSummary }
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Failed to parse the report: %v\n%s", err, output)
+	}
+	if report.Summary.Status[checksSuccess] != 2 || report.Summary.Status[checksFailure] != 1 {
+		t.Errorf("Unexpected summary: %+v", report.Summary)
+	}
+}
+
+func TestWatchChecksTimeout(t *testing.T) {
+	slept := mockSleep(t)
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/slow", Base: "main", Head: "dev"}).Checks = checksPending
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{"slow": {Repo: "org/slow", Base: "main", Head: "dev"}}}
+	var err error
+	output := captureStdout(t, func() {
+		err = watchChecks(config, watchOptions{timeout: time.Minute, interval: time.Hour})
+	})
+	if err == nil || !strings.Contains(err.Error(), "did not complete within 1m0s") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if len(*slept) != 0 {
+		t.Errorf("Expected no wait past the timeout, got %v", *slept)
+	}
+	if !strings.Contains(output, "Checks passed: 0, failed: 0, timed out: 1") {
+		t.Errorf("Expected a summary, got %q", output)
+	}
+}
+
+func TestWatchChecksWaitsForChecksToAppear(t *testing.T) {
+	slept := mockSleep(t)
+	be := useMemoryBackend(t)
+	be.add(Repo{Repo: "org/new", Base: "main", Head: "dev"}).Checks = checksNone
+	be.add(Repo{Repo: "org/bare", Base: "main", Head: "dev"}).Checks = checksNone
+
+	polls := 0
+	be.onStatus = func(pr *memoryPR) {
+		if pr.Repo == "org/new" {
+			if polls++; polls == 2 {
+				pr.Checks = checksSuccess
+			}
+		}
+	}
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{
+		"new":  {Repo: "org/new", Base: "main", Head: "dev"},
+		"bare": {Repo: "org/bare", Base: "main", Head: "dev"},
+	}}
+	var err error
+	output := captureStdout(t, func() {
+		err = watchChecks(config, watchOptions{timeout: time.Hour, interval: time.Minute, grace: 3 * time.Minute})
+	})
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	if len(*slept) != 3 {
+		t.Errorf("Expected PRs without checks to be polled for the grace period, got waits %v", *slept)
+	}
+	for _, want := range []string{"Checks passed for new", "No checks for bare", "Checks passed: 1, failed: 0, timed out: 0, no checks: 1"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "Checks passed for bare") {
+		t.Errorf("Expected a PR without checks not to be reported as passed, got:\n%s", output)
+	}
+}
+
+func TestWatchChecksOnGitLab(t *testing.T) {
+	mockSleep(t)
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/org%2Fr/merge_requests":
+			fmt.Fprint(w, `[{"iid": 5}]`)
+		case "/api/v4/projects/org%2Fr/merge_requests/5":
+			polls++
+			pipeline := "running"
+			if polls == 2 {
+				pipeline = "failed"
+			}
+			fmt.Fprintf(w, `{"iid": 5, "state": "opened", "head_pipeline": {"status": %q}}`, pipeline)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer server.Close()
+	originalMockBackend := mockBackend
+	defer func() { mockBackend = originalMockBackend }()
+	mockBackend = newGitLabBackend(server.URL+"/api/v4", "secret")
+
+	config := &Config{Defaults: Repo{Title: "T"}, Repos: map[string]Repo{"r": {Repo: "org/r", Base: "main", Head: "dev", Provider: providerGitLab}}}
+	var err error
+	captureStdout(t, func() {
+		err = watchChecks(config, watchOptions{timeout: time.Hour, interval: time.Minute})
+	})
+	if err == nil || !strings.Contains(err.Error(), "checks failed for r") || polls != 2 {
+		t.Errorf("Expected the failed pipeline to be reported after two polls, got %v (%d polls)", err, polls)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspaceRemote is the remote of local clones that pull requests are opened against
+const workspaceRemote = "origin"
+
+// expandHome replaces a leading ~ in path with the home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// localClone returns the directory of the clone of a repository in workspace:
+// the entry's `path` if set, otherwise <workspace>/<owner>/<name> or <workspace>/<name>
+func localClone(workspace string, details Repo) (string, error) {
+	root, err := expandHome(workspace)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	if details.Path != "" {
+		candidates = []string{details.Path}
+	} else {
+		_, name, _ := strings.Cut(details.Repo, "/")
+		candidates = []string{filepath.FromSlash(details.Repo), name}
+	}
+	for _, candidate := range candidates {
+		dir := filepath.Join(root, candidate)
+		if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && (info.IsDir() || info.Mode().IsRegular()) {
+			return dir, nil
+		}
+	}
+	return "", withClass(classValidation, fmt.Errorf("no clone of %s found in %s (tried %s)", details.Repo, root, strings.Join(candidates, ", ")))
+}
+
+// syncLocalBranch makes sure the head branch of the local clone in dir is on the remote:
+// the branch must exist locally, and is pushed when the remote branch is missing or behind.
+// A remote branch with commits the local one does not have is left alone and reported as an error.
+// It returns a description of what was (or, with dryRun, would be) done.
+func syncLocalBranch(ctx context.Context, retry retrier, dir string, details Repo, dryRun bool) (string, error) {
+	git := func(ctx context.Context, args ...string) (string, error) {
+		out, err := runCommandIn(ctx, dir, nil, append([]string{"git"}, args...)...)
+		return strings.TrimSpace(string(out)), err
+	}
+	ref := "refs/heads/" + details.Head
+
+	local, err := git(ctx, "rev-parse", "--verify", "--quiet", ref)
+	if err != nil || local == "" {
+		return "", withClass(classValidation, fmt.Errorf("branch %s does not exist in %s", details.Head, dir))
+	}
+	if remoteURL, err := git(ctx, "remote", "get-url", workspaceRemote); err == nil &&
+		!strings.Contains(strings.ToLower(remoteURL), strings.ToLower(details.Repo)) {
+		log.Printf("Warning: remote %s of %s (%s) does not look like %s\n", workspaceRemote, dir, remoteURL, details.Repo)
+	}
+
+	var remote string
+	err = retry.do(ctx, "Looking up "+details.Head+" on "+workspaceRemote+" of "+dir, func(ctx context.Context) (err error) {
+		var out string
+		out, err = git(ctx, "ls-remote", workspaceRemote, ref)
+		remote, _, _ = strings.Cut(out, "\t")
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s on %s: %w", details.Head, workspaceRemote, err)
+	}
+
+	var action string
+	switch {
+	case remote == local:
+		return fmt.Sprintf("%s is up to date on %s", details.Head, workspaceRemote), nil
+	case remote == "":
+		action = fmt.Sprintf("pushed %s to %s (new branch)", details.Head, workspaceRemote)
+	default:
+		if _, err := git(ctx, "merge-base", "--is-ancestor", remote, local); err != nil {
+			return "", withClass(classValidation, fmt.Errorf("%s on %s has commits that are not in %s, pull them first", details.Head, workspaceRemote, dir))
+		}
+		action = fmt.Sprintf("pushed %s to %s (remote was behind)", details.Head, workspaceRemote)
+	}
+	if dryRun {
+		return "would have " + action, nil
+	}
+
+	err = retry.do(ctx, "Pushing "+details.Head+" from "+dir, func(ctx context.Context) error {
+		_, err := git(ctx, "push", "--quiet", workspaceRemote, ref+":"+ref)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to push %s: %w", details.Head, err)
+	}
+	return action, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newLocalClone clones origin into dir with a committer identity configured
+func newLocalClone(t *testing.T, origin, dir string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"clone", "--quiet", origin, dir},
+		{"-C", dir, "config", "user.name", "Test"},
+		{"-C", dir, "config", "user.email", "test@example.com"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+}
+
+// commitFile commits a file on branch in the clone in dir, creating the branch if needed
+func commitFile(t *testing.T, dir, branch, name string) {
+	t.Helper()
+	gitOutput(t, dir, "checkout", "--quiet", "-B", branch)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, dir, "add", name)
+	gitOutput(t, dir, "commit", "--quiet", "-m", "Add "+name)
+}
+
+func TestLocalClone(t *testing.T) {
+	workspace := t.TempDir()
+	for _, dir := range []string{"org/a/.git", "b/.git", "custom/c/.git"} {
+		if err := os.MkdirAll(filepath.Join(workspace, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		details Repo
+		want    string
+	}{
+		{Repo{Repo: "org/a"}, "org/a"},
+		{Repo{Repo: "org/b"}, "b"},
+		{Repo{Repo: "org/c", Path: "custom/c"}, "custom/c"},
+	}
+	for _, tt := range tests {
+		if got, err := localClone(workspace, tt.details); err != nil || got != filepath.Join(workspace, tt.want) {
+			t.Errorf("localClone(%s) = %s, %v; expected %s", tt.details.Repo, got, err, tt.want)
+		}
+	}
+	if _, err := localClone(workspace, Repo{Repo: "org/c"}); classOf(err) != classValidation {
+		t.Errorf("Expected a validation error for a missing clone, got %v", err)
+	}
+
+	t.Setenv("HOME", workspace)
+	if got, err := localClone("~", Repo{Repo: "org/a"}); err != nil || got != filepath.Join(workspace, "org/a") {
+		t.Errorf("Expected ~ to be expanded, got %s, %v", got, err)
+	}
+}
+
+func TestCreatePullRequestFromWorkspace(t *testing.T) {
+	origin := newOriginRepo(t)
+	workspace := t.TempDir()
+	newLocalClone(t, origin, filepath.Join(workspace, "org", "a"))
+	clone := filepath.Join(workspace, "org", "a")
+	be := useMemoryBackend(t)
+
+	commitFile(t, clone, "feature", "one.txt")
+	config := func() *Config {
+		return &Config{Workspace: workspace, OnExisting: existingSkip, Repos: map[string]Repo{
+			"a": {Repo: "org/a", Base: "main", Head: "feature", Title: "Feature"},
+		}}
+	}
+
+	// A dry run does not push
+	output := captureStdout(t, func() {
+		if err := createPullRequest(config(), true); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if !strings.Contains(output, "would have pushed feature to origin (new branch)") {
+		t.Errorf("Unexpected dry run output: %q", output)
+	}
+	if branches := gitOutput(t, origin, "branch", "--list", "feature"); branches != "" {
+		t.Fatalf("Expected nothing to be pushed in a dry run, got %s", branches)
+	}
+
+	// The new branch is pushed and the PR opened
+	captureStdout(t, func() {
+		if err := createPullRequest(config(), false); err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+	if got, want := gitOutput(t, origin, "rev-parse", "feature"), gitOutput(t, clone, "rev-parse", "feature"); got != want {
+		t.Errorf("Expected origin to have the local branch, got %s instead of %s", got, want)
+	}
+	if len(be.prs) != 1 {
+		t.Errorf("Expected a PR, got %v", be.calls)
+	}
+
+	// A remote that is behind is pushed to, an up to date one is left alone
+	commitFile(t, clone, "feature", "two.txt")
+	for _, want := range []string{"remote was behind", "is up to date"} {
+		output := captureStdout(t, func() {
+			if err := createPullRequest(config(), false); err != nil {
+				t.Errorf("Expected nil error, got %v", err)
+			}
+		})
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in the output, got %q", want, output)
+		}
+	}
+
+	// Commits on the remote that are not in the clone fail the entry
+	other := filepath.Join(t.TempDir(), "other")
+	newLocalClone(t, origin, other)
+	gitOutput(t, other, "checkout", "--quiet", "feature")
+	commitFile(t, other, "feature", "three.txt")
+	gitOutput(t, other, "push", "--quiet", "origin", "feature")
+	err := createPullRequest(config(), false)
+	if err == nil || !strings.Contains(err.Error(), "pull them first") {
+		t.Errorf("Expected an error for a diverged branch, got %v", err)
+	}
+}
+
+func TestSyncLocalBranchMissingBranch(t *testing.T) {
+	origin := newOriginRepo(t)
+	clone := filepath.Join(t.TempDir(), "a")
+	newLocalClone(t, origin, clone)
+
+	_, err := syncLocalBranch(context.Background(), retrier{}, clone, Repo{Repo: "org/a", Head: "missing"}, false)
+	if classOf(err) != classValidation || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+}